@@ -0,0 +1,154 @@
+// Package agenttest provides a stand-in Sensu agent, for testing plugins that
+// publish events with package agent.
+package agenttest
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/agent"
+)
+
+// Server records the events published to its events API and its TCP and UDP
+// sockets. Socket results are converted to events, as the agent does.
+type Server struct {
+	// URL is the base URL of the events API, for agent.Client.
+	URL string
+
+	// TCPAddress and UDPAddress are the addresses of the sockets, for
+	// agent.SocketClient.
+	TCPAddress string
+	UDPAddress string
+
+	http *httptest.Server
+	tcp  net.Listener
+	udp  net.PacketConn
+	wg   sync.WaitGroup
+
+	mu     sync.Mutex
+	events []*corev2.Event
+}
+
+// NewServer starts a Server on local ports. Call Close when done with it.
+func NewServer() *Server {
+	s := &Server{}
+	s.http = httptest.NewServer(http.HandlerFunc(s.handleEvents))
+	s.URL = s.http.URL
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("agenttest: failed to listen: " + err.Error())
+	}
+	s.tcp = tcp
+	s.TCPAddress = tcp.Addr().String()
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		panic("agenttest: failed to listen: " + err.Error())
+	}
+	s.udp = udp
+	s.UDPAddress = udp.LocalAddr().String()
+
+	s.wg.Add(2)
+	go s.serveTCP()
+	go s.serveUDP()
+	return s
+}
+
+// Events returns the events published so far.
+func (s *Server) Events() []*corev2.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*corev2.Event(nil), s.events...)
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.http.Close()
+	_ = s.tcp.Close()
+	_ = s.udp.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) record(event *corev2.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/events" {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	event := new(corev2.Event)
+	if err := json.NewDecoder(req.Body).Decode(event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if event.Check == nil {
+		http.Error(w, "event must have a check", http.StatusBadRequest)
+		return
+	}
+	s.record(event)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) serveTCP() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(conn, 1<<20))
+		if err == nil && s.recordResult(b) {
+			_, _ = conn.Write([]byte("ok"))
+		} else {
+			_, _ = conn.Write([]byte("invalid"))
+		}
+		_ = conn.Close()
+	}
+}
+
+func (s *Server) serveUDP() {
+	defer s.wg.Done()
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		s.recordResult(buf[:n])
+	}
+}
+
+// recordResult records a socket result as an event, telling if it was valid.
+func (s *Server) recordResult(b []byte) bool {
+	var result agent.SocketResult
+	if err := json.Unmarshal(b, &result); err != nil || result.Name == "" {
+		return false
+	}
+	s.record(&corev2.Event{
+		Check: &corev2.Check{
+			ObjectMeta:      corev2.ObjectMeta{Name: result.Name},
+			Output:          result.Output,
+			Status:          result.Status,
+			ProxyEntityName: result.Source,
+			Handlers:        result.Handlers,
+			Ttl:             result.TTL,
+			Executed:        result.Executed,
+		},
+	})
+	return true
+}
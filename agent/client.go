@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+const (
+	// DefaultAPIURL is the URL of the agent events API on a default agent.
+	DefaultAPIURL = "http://127.0.0.1:3031"
+
+	// DefaultSocketAddress is the address of the agent socket on a default
+	// agent.
+	DefaultSocketAddress = "127.0.0.1:3030"
+)
+
+// Publisher publishes events through the agent. Client and SocketClient are
+// both Publishers.
+type Publisher interface {
+	Publish(ctx context.Context, event *corev2.Event) error
+}
+
+// Client publishes events with the agent events API.
+type Client struct {
+	// URL is the base URL of the agent API. The default is DefaultAPIURL.
+	URL string
+
+	// HTTPClient is the client used for requests. The default is
+	// http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// NewClient creates a Client for the agent API at url. An empty url is
+// DefaultAPIURL.
+func NewClient(url string) *Client {
+	return &Client{URL: url}
+}
+
+// Publish posts event to the agent events API. The agent fills in its own
+// entity if the event has none, and creates a proxy entity if the event's
+// check has a proxy entity name.
+func (c *Client) Publish(ctx context.Context, event *corev2.Event) error {
+	if event == nil || event.Check == nil {
+		return fmt.Errorf("failed to publish event: no check")
+	}
+	b, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %s", err)
+	}
+	url := c.URL
+	if url == "" {
+		url = DefaultAPIURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(url, "/")+"/events", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return sensu.Transient(fmt.Errorf("failed to publish event: %w", err))
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("failed to publish event: agent API returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		if resp.StatusCode >= 500 {
+			err = sensu.Transient(err)
+		}
+		return err
+	}
+	return nil
+}
+
+// ProxyEvent creates an event for a check result of the proxy entity named
+// entity. The result is rendered as the check's output.
+func ProxyEvent(entity, check string, result *sensu.CheckResult) (*corev2.Event, error) {
+	var output bytes.Buffer
+	if err := result.Render(&output); err != nil {
+		return nil, err
+	}
+	return &corev2.Event{
+		Check: &corev2.Check{
+			ObjectMeta:      corev2.ObjectMeta{Name: check},
+			ProxyEntityName: entity,
+			Status:          uint32(result.Status),
+			Output:          output.String(),
+			Executed:        time.Now().Unix(),
+		},
+	}, nil
+}
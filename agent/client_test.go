@@ -0,0 +1,91 @@
+package agent_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/agent"
+	"github.com/sensu/sensu-plugin-sdk/agent/agenttest"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func proxyEvent(t *testing.T) *corev2.Event {
+	t.Helper()
+	event, err := agent.ProxyEvent("switch-1", "ping", &sensu.CheckResult{
+		Status:  sensu.CheckStateCritical,
+		Summary: "no reply",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func assertProxyEvent(t *testing.T, events []*corev2.Event) {
+	t.Helper()
+	if assert.Len(t, events, 1) {
+		check := events[0].Check
+		assert.Equal(t, "ping", check.Name)
+		assert.Equal(t, "switch-1", check.ProxyEntityName)
+		assert.Equal(t, uint32(sensu.CheckStateCritical), check.Status)
+		assert.Equal(t, "CRITICAL: no reply\n", check.Output)
+	}
+}
+
+func TestClientPublish(t *testing.T) {
+	server := agenttest.NewServer()
+	defer server.Close()
+
+	client := agent.NewClient(server.URL)
+	assert.NoError(t, client.Publish(context.Background(), proxyEvent(t)))
+	assertProxyEvent(t, server.Events())
+
+	assert.Error(t, client.Publish(context.Background(), &corev2.Event{}))
+}
+
+func TestClientPublishErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "agent is shutting down", http.StatusServiceUnavailable)
+	}))
+	client := agent.NewClient(server.URL)
+	err := client.Publish(context.Background(), proxyEvent(t))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "agent is shutting down")
+	assert.True(t, sensu.IsTransient(err))
+
+	server.Close()
+	err = client.Publish(context.Background(), proxyEvent(t))
+	assert.True(t, sensu.IsTransient(err))
+}
+
+func TestSocketClientPublishTCP(t *testing.T) {
+	server := agenttest.NewServer()
+	defer server.Close()
+
+	client := &agent.SocketClient{Address: server.TCPAddress}
+	assert.NoError(t, client.Publish(context.Background(), proxyEvent(t)))
+	assertProxyEvent(t, server.Events())
+
+	invalid := proxyEvent(t)
+	invalid.Check.Name = ""
+	err := client.Publish(context.Background(), invalid)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid")
+}
+
+func TestSocketClientPublishUDP(t *testing.T) {
+	server := agenttest.NewServer()
+	defer server.Close()
+
+	client := &agent.SocketClient{Network: "udp", Address: server.UDPAddress}
+	assert.NoError(t, client.Publish(context.Background(), proxyEvent(t)))
+	assert.Eventually(t, func() bool {
+		return len(server.Events()) == 1
+	}, time.Second, 10*time.Millisecond)
+	assertProxyEvent(t, server.Events())
+}
@@ -0,0 +1,8 @@
+// Package agent publishes events through the Sensu agent. Checks use it to
+// report results for proxy entities, such as the remote devices that they
+// monitor, in addition to their own result.
+//
+// Client uses the agent events API (http://127.0.0.1:3031/events by default),
+// and SocketClient uses the agent socket (127.0.0.1:3030), over TCP or UDP.
+// Package agenttest provides a stand-in agent for tests.
+package agent
@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// SocketResult is a check result in the format accepted by the agent socket.
+// Source is the name of the proxy entity that the result is for.
+type SocketResult struct {
+	Name     string   `json:"name"`
+	Output   string   `json:"output"`
+	Status   uint32   `json:"status"`
+	Source   string   `json:"source,omitempty"`
+	Handlers []string `json:"handlers,omitempty"`
+	TTL      int64    `json:"ttl,omitempty"`
+	Executed int64    `json:"executed,omitempty"`
+}
+
+// NewSocketResult converts event to a SocketResult. The proxy entity name of
+// the event's check is the result's source.
+func NewSocketResult(event *corev2.Event) (SocketResult, error) {
+	if event == nil || event.Check == nil {
+		return SocketResult{}, fmt.Errorf("no check")
+	}
+	return SocketResult{
+		Name:     event.Check.Name,
+		Output:   event.Check.Output,
+		Status:   event.Check.Status,
+		Source:   event.Check.ProxyEntityName,
+		Handlers: event.Check.Handlers,
+		TTL:      event.Check.Ttl,
+		Executed: event.Check.Executed,
+	}, nil
+}
+
+// SocketClient publishes events with the agent socket.
+type SocketClient struct {
+	// Network is "tcp" or "udp". The default is "tcp".
+	Network string
+
+	// Address is the address of the agent socket. The default is
+	// DefaultSocketAddress.
+	Address string
+
+	// Timeout bounds how long publishing an event takes, if ctx has no
+	// deadline. The default is 10 seconds.
+	Timeout time.Duration
+}
+
+// Publish sends event to the agent socket. Over TCP, an "invalid" response
+// from the agent is an error; over UDP, delivery isn't confirmed.
+func (c *SocketClient) Publish(ctx context.Context, event *corev2.Event) error {
+	result, err := NewSocketResult(event)
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %s", err)
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %s", err)
+	}
+
+	network, address := c.Network, c.Address
+	if network == "" {
+		network = "tcp"
+	}
+	if address == "" {
+		address = DefaultSocketAddress
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := c.Timeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return sensu.Transient(fmt.Errorf("failed to publish event: %w", err))
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	if _, err := conn.Write(b); err != nil {
+		return sensu.Transient(fmt.Errorf("failed to publish event: %w", err))
+	}
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if err := tcp.CloseWrite(); err != nil {
+		return sensu.Transient(fmt.Errorf("failed to publish event: %w", err))
+	}
+	response, err := ioutil.ReadAll(tcp)
+	if err != nil {
+		return sensu.Transient(fmt.Errorf("failed to publish event: %w", err))
+	}
+	if r := strings.TrimSpace(string(response)); r == "invalid" {
+		return fmt.Errorf("failed to publish event: agent socket returned %q", r)
+	}
+	return nil
+}
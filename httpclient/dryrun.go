@@ -0,0 +1,141 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	redacted = "REDACTED"
+
+	// DefaultDryRunBodySize is the number of bytes of a request body that a
+	// DryRunTransport logs when MaxBodySize is not set.
+	DefaultDryRunBodySize = 4096
+)
+
+// sensitiveHeaderWords are the words that cause a header's value to be
+// redacted when they appear anywhere in its name.
+var sensitiveHeaderWords = []string{"auth", "cookie", "key", "password", "secret", "session", "signature", "token"}
+
+// DryRunTransport is an http.RoundTripper that logs the requests it is given
+// instead of sending them. Every request receives an empty 200 OK response.
+//
+// The values of headers that look like they carry credentials, such as
+// Authorization, Cookie or X-Api-Key, are redacted from the log.
+type DryRunTransport struct {
+	// Logger receives a description of every intercepted request. If Logger
+	// is nil, the standard logger is used.
+	Logger *log.Logger
+
+	// RedactHeaders lists the names of additional headers to redact.
+	RedactHeaders []string
+
+	// MaxBodySize limits the number of bytes of the request body that are
+	// logged. If zero, DefaultDryRunBodySize is used. If negative, the body
+	// is not logged.
+	MaxBodySize int
+}
+
+// RoundTrip logs the request and returns an empty 200 OK response.
+func (t *DryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := t.readBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("dry run: couldn't read request body: %s", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "dry run: %s %s", req.Method, req.URL.Redacted())
+	if headers := t.formatHeaders(req.Header); headers != "" {
+		fmt.Fprintf(&b, " headers: %s", headers)
+	}
+	if body != "" {
+		fmt.Fprintf(&b, " body: %s", body)
+	}
+	t.logf("%s", b.String())
+
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+func (t *DryRunTransport) logf(format string, args ...interface{}) {
+	if t.Logger != nil {
+		t.Logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func (t *DryRunTransport) readBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+	defer req.Body.Close()
+	limit := t.MaxBodySize
+	if limit == 0 {
+		limit = DefaultDryRunBodySize
+	}
+	if limit < 0 {
+		_, err := io.Copy(ioutil.Discard, req.Body)
+		return "", err
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, int64(limit)+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > limit {
+		// drain the rest, as a real transport would have sent it
+		if _, err := io.Copy(ioutil.Discard, req.Body); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s... (truncated)", body[:limit]), nil
+	}
+	return string(body), nil
+}
+
+func (t *DryRunTransport) formatHeaders(header http.Header) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]string, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(header.Values(name), ", ")
+		if t.sensitive(name) {
+			value = redacted
+		}
+		entries = append(entries, fmt.Sprintf("%s: %s", name, value))
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(entries, "; ") + "}"
+}
+
+func (t *DryRunTransport) sensitive(name string) bool {
+	lower := strings.ToLower(name)
+	for _, word := range sensitiveHeaderWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	for _, header := range t.RedactHeaders {
+		if strings.EqualFold(header, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package sensu
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/spf13/cobra"
+)
+
+// setupBatchFlags adds the flags that control batch mode. Plugins that
+// support batch mode call it when setting up their own flags.
+func (p *pluginFramework) setupBatchFlags(cmd *cobra.Command) {
+	if cmd.Flags().Lookup("batch") == nil {
+		cmd.Flags().BoolVar(&p.batch, "batch", false, "Read a stream of newline-delimited events, or a JSON array of events, and process each of them")
+	}
+	if cmd.Flags().Lookup("batch-concurrency") == nil {
+		cmd.Flags().IntVar(&p.batchConcurrency, "batch-concurrency", 1, "The maximum number of events processed at the same time in batch mode")
+	}
+}
+
+// batchFunction runs the plugin for every event read from stdin, or from
+// --event-file. Each event is processed with its own configuration overrides.
+// A summary is logged once all of the events have been processed, and the
+// plugin fails if any of the events failed.
+func (p *pluginFramework) batchFunction() error {
+	p.exitStatus = p.errorExitStatus
+	if p.pluginEventFunction == nil {
+		return errors.New("batch mode is not supported by this plugin")
+	}
+	if p.eventFromAPI != "" {
+		return errors.New("--batch can't be used with --event-from-api")
+	}
+	if err := p.validateAllowRestrict(); err != nil {
+		return err
+	}
+
+	reader := p.eventReader
+	if p.eventFile != "" {
+		f, err := os.Open(p.eventFile)
+		if err != nil {
+			return fmt.Errorf("failed to open event file: %s", err)
+		}
+		defer f.Close()
+		reader = f
+	}
+	decoder, err := newEventDecoder(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read events: %s", err)
+	}
+
+	concurrency := p.batchConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var (
+		runner    = newEventRunner(p, concurrency)
+		semaphore = make(chan struct{}, concurrency)
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		total     int
+		failed    int
+		readErr   error
+	)
+	fail := func(n int, key string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed++
+		log.Printf("batch: event %d (%s) failed: %s", n, key, err)
+	}
+
+	for {
+		raw, err := decoder.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// the stream can't be resynchronized after malformed JSON
+			readErr = fmt.Errorf("failed to read event %d: %s", total+1, err)
+			break
+		}
+		total++
+		n := total
+		event := new(corev2.Event)
+		if err := json.Unmarshal(raw, event); err != nil {
+			fail(n, EventKey(nil), fmt.Errorf("failed to unmarshal event: %s", err))
+			continue
+		}
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			// each event gets the plugin's timeout, so that an event that
+			// gets stuck fails rather than holding up the batch
+			ctx, cancel := p.timeoutContext()
+			defer cancel()
+			if _, err := runner.run(ctx, event, os.Stdout); err != nil {
+				fail(n, EventKey(event), err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	log.Printf("batch: processed %d events: %d succeeded, %d failed", total, total-failed, failed)
+	if readErr != nil {
+		return readErr
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d events failed", failed, total)
+	}
+	p.exitStatus = 0
+	return nil
+}
+
+// eventDecoder reads events from a stream of JSON values, which are usually
+// newline-delimited, or from a single JSON array.
+type eventDecoder struct {
+	decoder *json.Decoder
+	array   bool
+}
+
+func newEventDecoder(r io.Reader) (*eventDecoder, error) {
+	reader := bufio.NewReader(r)
+	var first byte
+	for {
+		b, err := reader.Peek(1)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b[0] == ' ' || b[0] == '\t' || b[0] == '\r' || b[0] == '\n' {
+			_, _ = reader.ReadByte()
+			continue
+		}
+		first = b[0]
+		break
+	}
+	d := &eventDecoder{decoder: json.NewDecoder(reader)}
+	if first == '[' {
+		d.array = true
+		if _, err := d.decoder.Token(); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// next returns the next event in the stream, or io.EOF if there are no more.
+func (d *eventDecoder) next() (json.RawMessage, error) {
+	if d.array && !d.decoder.More() {
+		if _, err := d.decoder.Token(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	var raw json.RawMessage
+	if err := d.decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
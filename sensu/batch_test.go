@@ -0,0 +1,145 @@
+package sensu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func compactEventFile(t *testing.T, file string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := json.Compact(buf, b); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func batchExecuteUtil(t *testing.T, input io.Reader, args []string, options []ConfigOption,
+	executeFunction func(*corev2.Event) error) (int, string) {
+	t.Helper()
+
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, options, noOp, executeFunction)
+	handler.framework.cmd.SetArgs(append([]string{"--batch"}, args...))
+
+	var exitStatus = -99
+	var errorStr string
+	handler.framework.eventReader = input
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.framework.errorLogFunction = func(format string, a ...interface{}) {
+		errorStr = fmt.Sprintf(format, a...)
+	}
+	handler.Execute()
+
+	return exitStatus, errorStr
+}
+
+func TestBatchNDJSON(t *testing.T) {
+	clearEnvironment()
+	input := strings.Join([]string{
+		compactEventFile(t, "test/event-check-override.json"),
+		compactEventFile(t, "test/event-no-override.json"),
+		compactEventFile(t, "test/event-invalid-check.json"),
+	}, "\n")
+
+	values := handlerValues{}
+	var seen []string
+	status, errorStr := batchExecuteUtil(t, strings.NewReader(input), []string{"--string", "value-arg1"}, getHandlerOptions(&values),
+		func(event *corev2.Event) error {
+			seen = append(seen, values.arg1)
+			return nil
+		})
+
+	assert.Equal(t, 1, status)
+	assert.Contains(t, errorStr, "1 of 3 events failed")
+	// overrides only apply to the event that carries them
+	assert.Equal(t, []string{"value-check1", "value-arg1"}, seen)
+	assert.Equal(t, "value-arg1", values.arg1)
+}
+
+func TestBatchJSONArrayConcurrent(t *testing.T) {
+	clearEnvironment()
+	event := compactEventFile(t, "test/event-no-override.json")
+	input := "[" + strings.Join([]string{event, event, event, event, event}, ",\n") + "]"
+
+	var count int32
+	status, errorStr := batchExecuteUtil(t, strings.NewReader(input), []string{"--batch-concurrency", "4"}, nil,
+		func(event *corev2.Event) error {
+			atomic.AddInt32(&count, 1)
+			return nil
+		})
+
+	assert.Equal(t, 0, status)
+	assert.Empty(t, errorStr)
+	assert.Equal(t, int32(5), count)
+}
+
+func TestBatchMalformedStream(t *testing.T) {
+	clearEnvironment()
+	input := compactEventFile(t, "test/event-no-override.json") + "\n{not json"
+
+	var count int
+	status, errorStr := batchExecuteUtil(t, strings.NewReader(input), nil, nil,
+		func(event *corev2.Event) error {
+			count++
+			return nil
+		})
+
+	assert.Equal(t, 1, status)
+	assert.Contains(t, errorStr, "failed to read event 2")
+	assert.Equal(t, 1, count)
+}
+
+func TestBatchEventTimeout(t *testing.T) {
+	clearEnvironment()
+	config := defaultHandlerConfig
+	config.Timeout = 1
+	event := compactEventFile(t, "test/event-no-override.json")
+	input := strings.Join([]string{event, event, event}, "\n")
+
+	// the first event gets stuck, and the others are handled once it times out
+	release := make(chan struct{})
+	defer close(release)
+	var calls int32
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&config, nil, noOp, func(event *corev2.Event) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+		}
+		return nil
+	})
+	handler.framework.cmd.SetArgs([]string{"--batch", "--batch-concurrency", "2"})
+
+	var exitStatus = -99
+	var errorStr string
+	handler.framework.eventReader = strings.NewReader(input)
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.framework.errorLogFunction = func(format string, a ...interface{}) {
+		errorStr = fmt.Sprintf(format, a...)
+	}
+	start := time.Now()
+	handler.Execute()
+
+	assert.Equal(t, 1, exitStatus)
+	assert.Contains(t, errorStr, "1 of 3 events failed")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
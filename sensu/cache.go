@@ -0,0 +1,88 @@
+package sensu
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu/state"
+	"github.com/spf13/pflag"
+)
+
+// cachedResult is the result of a check, as it is cached.
+type cachedResult struct {
+	Status int    `json:"status"`
+	Output string `json:"output"`
+}
+
+// EnableCache makes the check reuse its result for ttl, rather than running
+// again. The status and output of a successful run are cached in a local
+// file, keyed by the name of the check, the values of its flags and options,
+// after annotation overrides, and the event's entity and check names. The
+// --no-cache flag bypasses the cache.
+func (c *Check) EnableCache(ttl time.Duration) {
+	c.cacheTTL = ttl
+	if c.framework.cmd != nil && c.framework.cmd.Flags().Lookup("no-cache") == nil {
+		c.framework.cmd.Flags().BoolVar(&c.noCache, "no-cache", false, "Run the check, rather than reusing a cached result")
+	}
+}
+
+// cachedRun writes the cached result of the check, if there is one, and runs
+// the check and caches its result otherwise. Problems with the cache are
+// logged, and the check runs as if it wasn't cached.
+func (c *Check) cachedRun(args []string) (int, error) {
+	store, err := state.Open(c.framework.config.Name+"-cache", state.Options{Dir: c.cacheDir, TTL: c.cacheTTL})
+	if err != nil {
+		log.Printf("check result cache unavailable: %s", err)
+		return c.run(args)
+	}
+	key := c.cacheKey()
+
+	var cached cachedResult
+	found, err := store.Get(key, &cached)
+	if err != nil {
+		log.Printf("failed to read cached check result: %s", err)
+	}
+	if found {
+		_, _ = io.WriteString(c.out, cached.Output)
+		return cached.Status, nil
+	}
+
+	var output bytes.Buffer
+	restore, err := c.redirectOutput(io.MultiWriter(c.out, &output))
+	if err != nil {
+		log.Printf("failed to capture check output for caching: %s", err)
+		return c.run(args)
+	}
+	status, err := func() (int, error) {
+		defer restore()
+		return c.run(args)
+	}()
+	if err != nil {
+		return status, err
+	}
+	if err := store.Set(key, cachedResult{Status: status, Output: output.String()}); err != nil {
+		log.Printf("failed to cache check result: %s", err)
+	}
+	return status, nil
+}
+
+// cacheKey identifies the check's configuration. Values are hashed, so
+// that secrets aren't written to the cache.
+func (c *Check) cacheKey() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", c.framework.config.Name)
+	c.framework.cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Name != "no-cache" {
+			fmt.Fprintf(h, "%s=%s\n", flag.Name, flag.Value)
+		}
+	})
+	if event := c.framework.GetStdinEvent(); event != nil {
+		fmt.Fprintf(h, "event=%s\n", EventKey(event))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,63 @@
+package sensu
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func cachedCheckExecuteUtil(t *testing.T, dir string, args []string, runs *int) (int, string) {
+	t.Helper()
+	values := checkValues{}
+	noOp := func(*corev2.Event) (int, error) { return 0, nil }
+	check := NewCheck(&defaultCheckConfig, getCheckOptions(&values), noOp, func(*corev2.Event) (int, error) {
+		*runs++
+		fmt.Printf("run %d with %s\n", *runs, values.arg1)
+		return CheckStateWarning, nil
+	}, false)
+	check.EnableCache(time.Minute)
+	check.cacheDir = dir
+	check.framework.cmd.SetArgs(args)
+
+	var out bytes.Buffer
+	var exitStatus = -99
+	check.out = &out
+	check.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	restore, err := check.redirectOutput(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check.Execute()
+	restore()
+	return exitStatus, out.String()
+}
+
+func TestCheckCache(t *testing.T) {
+	clearEnvironment()
+	dir := t.TempDir()
+	var runs int
+
+	status, out := cachedCheckExecuteUtil(t, dir, []string{"--string", "a"}, &runs)
+	assert.Equal(t, CheckStateWarning, status)
+	assert.Equal(t, "run 1 with a\n", out)
+
+	// the cached result is reused
+	status, out = cachedCheckExecuteUtil(t, dir, []string{"--string", "a"}, &runs)
+	assert.Equal(t, CheckStateWarning, status)
+	assert.Equal(t, "run 1 with a\n", out)
+	assert.Equal(t, 1, runs)
+
+	// different options have their own result
+	_, out = cachedCheckExecuteUtil(t, dir, []string{"--string", "b"}, &runs)
+	assert.Equal(t, "run 2 with b\n", out)
+
+	// --no-cache bypasses the cache
+	_, out = cachedCheckExecuteUtil(t, dir, []string{"--string", "a", "--no-cache"}, &runs)
+	assert.Equal(t, "run 3 with a\n", out)
+}
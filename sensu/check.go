@@ -1,9 +1,13 @@
 package sensu
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"time"
 
 	corev2 "github.com/sensu/core/v2"
 )
@@ -24,6 +28,11 @@ type Check struct {
 	framework          pluginFramework
 	validationFunction func(event *corev2.Event) (int, error)
 	executeFunction    func(event *corev2.Event) (int, error)
+	resultFunction     func(event *corev2.Event) (*CheckResult, error)
+	out                io.Writer
+	cacheTTL           time.Duration
+	cacheDir           string
+	noCache            bool
 }
 
 // NewCheck creates a new check.
@@ -40,13 +49,15 @@ func NewCheck(config *PluginConfig, options []ConfigOption,
 			readEvent:              readEvent,
 			configurationOverrides: true,
 			verbose:                false,
-			errorExitStatus:        1,
+			errorExitStatus:        CheckStateUnknown,
 		},
 		validationFunction: validationFunction,
 		executeFunction:    executeFunction,
+		out:                os.Stdout,
 	}
 
 	check.framework.SetWorkflow(check.workflow)
+	check.framework.SetValidation(check.validate)
 	if err := check.framework.Init(); err != nil {
 		log.Printf("failed to initialize check plugin: %s", err)
 	}
@@ -54,28 +65,105 @@ func NewCheck(config *PluginConfig, options []ConfigOption,
 	return check
 }
 
+// NewCheckWithResult creates a new check whose execute function returns a
+// CheckResult, rather than printing its own output. The framework renders the
+// result to stdout and exits with its status.
+func NewCheckWithResult(config *PluginConfig, options []ConfigOption,
+	validationFunction func(*corev2.Event) (int, error),
+	executeFunction func(*corev2.Event) (*CheckResult, error), readEvent bool) *Check {
+	check := NewCheck(config, options, validationFunction, nil, readEvent)
+	check.resultFunction = executeFunction
+	return check
+}
+
 // NewGoCheck creates a new check.
 // Deprecated: use NewCheck
 var NewGoCheck = NewCheck
 
-// Executes the check
-func (c *Check) workflow(_ []string) (int, error) {
+// SetErrorStatus sets the exit status used when the check fails because of an
+// error, such as a panic or an invalid event, rather than because of what it
+// checked. Errors marked with Unknown, Critical or Warning use their own exit
+// status. The default is CheckStateUnknown.
+func (c *Check) SetErrorStatus(status int) {
+	c.framework.errorExitStatus = status
+}
+
+// Validates the check's input
+func (c *Check) validate(_ []string) (int, error) {
 	// Validate input using validateFunction
 	status, err := c.validationFunction(c.framework.GetStdinEvent())
 	if err != nil {
-		return status, ErrValidationFailed(err.Error())
+		return status, validationFailed(err)
+	}
+	return status, nil
+}
+
+// Context returns a context that is done when the check's timeout, set in
+// its PluginConfig, expires. Use it to bound the work done by the check's
+// execute function.
+func (c *Check) Context() context.Context {
+	return c.framework.context()
+}
+
+// Executes the check
+func (c *Check) workflow(args []string) (int, error) {
+	if max := c.framework.config.MaxOutputSize; max > 0 {
+		stop, err := c.limitOutput(max)
+		if err != nil {
+			return c.framework.errorExitStatus, err
+		}
+		defer stop()
+	}
+	if c.cacheTTL > 0 && !c.noCache {
+		return c.cachedRun(args)
+	}
+	return c.run(args)
+}
+
+// run validates the check's input and executes it.
+func (c *Check) run(args []string) (int, error) {
+	status, err := c.validate(args)
+	if err != nil {
+		return status, err
+	}
+
+	if c.resultFunction != nil {
+		return c.result(c.framework.GetStdinEvent())
 	}
 
 	// Execute check logic using executeFunction
 	status, err = c.executeFunction(c.framework.GetStdinEvent())
 	if err != nil {
-		return status, fmt.Errorf("error executing check: %s", err)
+		return status, fmt.Errorf("error executing check: %w", err)
 	}
 
 	return status, nil
 }
 
+// result runs the check's result function and renders its result. If the
+// function fails without a result, the check exits with its error status.
+func (c *Check) result(event *corev2.Event) (int, error) {
+	result, err := c.resultFunction(event)
+	if result == nil {
+		if err == nil {
+			err = errors.New("no result")
+		}
+		return c.framework.errorExitStatus, fmt.Errorf("error executing check: %w", err)
+	}
+	if renderErr := result.Render(c.out); renderErr != nil && err == nil {
+		err = renderErr
+	}
+	if err != nil {
+		return result.Status, fmt.Errorf("error executing check: %w", err)
+	}
+	return result.Status, nil
+}
+
 // Execute is the check's entry point.
 func (c *Check) Execute() {
 	c.framework.Execute()
 }
+
+func (c *Check) getFramework() *pluginFramework {
+	return &c.framework
+}
@@ -0,0 +1,104 @@
+package sensu
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// newCompletionCommand creates the completion subcommand. It replaces cobra's
+// default completion command so that plugins get it regardless of whether they
+// have other subcommands.
+func newCompletionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion [bash|zsh|fish|powershell]",
+		Short: "Generate the autocompletion script for the specified shell",
+		Long: `Generate the autocompletion script for the specified shell.
+
+To load completions in the current bash session:
+
+  source <(%[1]s completion bash)
+
+To load completions in the current zsh session:
+
+  source <(%[1]s completion zsh)
+
+To load completions in the current fish session:
+
+  %[1]s completion fish | source
+`,
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		Args:                  cobra.ExactValidArgs(1),
+		DisableFlagsInUseLine: true,
+		SilenceErrors:         true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			out := cmd.OutOrStdout()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(out, true)
+			case "zsh":
+				return root.GenZshCompletion(out)
+			case "fish":
+				return root.GenFishCompletion(out, true)
+			case "powershell":
+				return root.GenPowerShellCompletionWithDesc(out)
+			}
+			return fmt.Errorf("unsupported shell: %s", args[0])
+		},
+	}
+}
+
+// setupCompletion disables cobra's default completion command on cmd and adds
+// the plugin completion command in its place.
+func setupCompletion(cmd *cobra.Command) {
+	cmd.CompletionOptions.DisableDefaultCmd = true
+	completion := newCompletionCommand()
+	completion.Long = fmt.Sprintf(completion.Long, cmd.Name())
+	cmd.AddCommand(completion)
+}
+
+// registerFlagCompletion sets up shell completion for the value of the flag
+// with the given name. If filename is true, files are suggested. Otherwise,
+// the allowed values are suggested, if there are any.
+func registerFlagCompletion(cmd *cobra.Command, name string, filename bool, allowed []string) error {
+	if filename {
+		return cmd.MarkFlagFilename(name)
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	return cmd.RegisterFlagCompletionFunc(name, func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return allowed, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// completionValues formats values as suggestions for shell completion, with
+// duplicates removed.
+func completionValues[T any](values ...T) []string {
+	seen := make(map[string]struct{}, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		s := fmt.Sprint(value)
+		if _, ok := seen[s]; ok || s == "" {
+			continue
+		}
+		seen[s] = struct{}{}
+		result = append(result, s)
+	}
+	return result
+}
+
+// mapCompletionValues formats the entries of values as key=value suggestions
+// for shell completion.
+func mapCompletionValues[T MapOptionValue](values ...map[string]T) []string {
+	var entries []string
+	for _, m := range values {
+		for k, v := range m {
+			entries = append(entries, fmt.Sprintf("%s=%v", k, v))
+		}
+	}
+	sort.Strings(entries)
+	return completionValues(entries...)
+}
@@ -0,0 +1,66 @@
+package sensu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func completionExecuteUtil(t *testing.T, options []ConfigOption, args []string) string {
+	t.Helper()
+
+	noOp := func(*corev2.Event) (int, error) { return 0, nil }
+	check := NewCheck(&defaultCheckConfig, options, noOp, noOp, false)
+
+	out := new(bytes.Buffer)
+	check.framework.cmd.SetOut(out)
+	check.framework.cmd.SetArgs(args)
+	check.framework.exitFunction = func(int) {}
+	check.Execute()
+
+	return out.String()
+}
+
+func TestCompletionCommand(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		t.Run(shell, func(t *testing.T) {
+			out := completionExecuteUtil(t, nil, []string{"completion", shell})
+			assert.Contains(t, out, defaultCheckConfig.Name)
+		})
+	}
+}
+
+func TestCompletionAllowValues(t *testing.T) {
+	var value string
+	opt := PluginConfigOption[string]{
+		Argument: "color",
+		Default:  "red",
+		Allow:    []string{"green", "blue"},
+		Value:    &value,
+	}
+	out := completionExecuteUtil(t, []ConfigOption{&opt}, []string{"__complete", "--color", ""})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Equal(t, []string{"red", "green", "blue", ":4"}, lines[:4])
+}
+
+func TestCompletionMapAllowValues(t *testing.T) {
+	var value map[string]int
+	opt := MapPluginConfigOption[int]{
+		Argument: "limits",
+		Allow:    map[string]int{"cpu": 2, "mem": 4},
+		Value:    &value,
+	}
+	out := completionExecuteUtil(t, []ConfigOption{&opt}, []string{"__complete", "--limits", ""})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Equal(t, []string{"cpu=2", "mem=4", ":4"}, lines[:3])
+}
+
+func TestCompletionFilename(t *testing.T) {
+	var config SecurityConfig
+	out := completionExecuteUtil(t, SensuSecurityOptions(&config), []string{"__complete", "--sensu-ca-cert", ""})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Equal(t, ":0", lines[0])
+}
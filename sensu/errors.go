@@ -0,0 +1,112 @@
+package sensu
+
+import (
+	"errors"
+	"fmt"
+)
+
+// statusError is an error that sets the plugin's exit status.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// transientError is an error that may not happen again if the plugin is
+// retried.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// Unknown marks err as a problem that stopped the plugin from doing its job,
+// such as bad configuration. The plugin exits with CheckStateUnknown.
+func Unknown(err error) error {
+	return withStatus(CheckStateUnknown, err)
+}
+
+// Critical marks err as critical. The plugin exits with CheckStateCritical.
+func Critical(err error) error {
+	return withStatus(CheckStateCritical, err)
+}
+
+// Warning marks err as a warning. The plugin exits with CheckStateWarning.
+func Warning(err error) error {
+	return withStatus(CheckStateWarning, err)
+}
+
+func withStatus(status int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &statusError{status: status, err: err}
+}
+
+// Transient marks err as temporary, such as a network timeout, which may not
+// happen again if the plugin is retried. It can be combined with Unknown,
+// Critical and Warning, which set the exit status.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// IsTransient tells if err, or any error it wraps, was marked with Transient.
+func IsTransient(err error) bool {
+	var t *transientError
+	return errors.As(err, &t)
+}
+
+// ErrorStatus returns the exit status set by Unknown, Critical or Warning on
+// err or any error it wraps. The second return value is false if err has no
+// exit status.
+func ErrorStatus(err error) (int, bool) {
+	var s *statusError
+	if errors.As(err, &s) {
+		return s.status, true
+	}
+	return 0, false
+}
+
+// validationFailed converts err to ErrValidationFailed, keeping its exit
+// status and whether it is transient.
+func validationFailed(err error) error {
+	var verr error = ErrValidationFailed(err.Error())
+	if status, ok := ErrorStatus(err); ok {
+		verr = withStatus(status, verr)
+	}
+	if IsTransient(err) {
+		verr = Transient(verr)
+	}
+	return verr
+}
+
+// errorExit returns the exit status for the result of one of the plugin's
+// functions. An exit status set on err takes precedence over status.
+func (p *pluginFramework) errorExit(status int, err error) (int, error) {
+	if err == nil {
+		return status, nil
+	}
+	if s, ok := ErrorStatus(err); ok {
+		status = s
+	}
+	if IsTransient(err) {
+		err = fmt.Errorf("%w (transient)", err)
+	}
+	return status, err
+}
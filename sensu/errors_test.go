@@ -0,0 +1,151 @@
+package sensu
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorStatus(t *testing.T) {
+	cause := errors.New("cause")
+
+	tests := []struct {
+		err       error
+		status    int
+		ok        bool
+		transient bool
+	}{
+		{cause, 0, false, false},
+		{Unknown(cause), CheckStateUnknown, true, false},
+		{Critical(cause), CheckStateCritical, true, false},
+		{Warning(cause), CheckStateWarning, true, false},
+		{Transient(cause), 0, false, true},
+		{Transient(Critical(cause)), CheckStateCritical, true, true},
+		{fmt.Errorf("wrapped: %w", Transient(Unknown(cause))), CheckStateUnknown, true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.err.Error(), func(t *testing.T) {
+			status, ok := ErrorStatus(test.err)
+			assert.Equal(t, test.status, status)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.transient, IsTransient(test.err))
+			assert.True(t, errors.Is(test.err, cause))
+		})
+	}
+
+	assert.Nil(t, Unknown(nil))
+	assert.Nil(t, Transient(nil))
+}
+
+func checkErrorExecuteUtil(t *testing.T, check *Check, eventFile string) (int, string) {
+	t.Helper()
+	check.framework.cmd.SetArgs([]string{})
+
+	var exitStatus = -99
+	var errorStr string
+	check.framework.eventReader = getFileReader(eventFile)
+	check.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	check.framework.errorLogFunction = func(format string, a ...interface{}) {
+		errorStr = fmt.Sprintf(format, a...)
+	}
+	check.Execute()
+
+	return exitStatus, errorStr
+}
+
+func TestCheckErrorStatus(t *testing.T) {
+	noOp := func(*corev2.Event) (int, error) { return 0, nil }
+
+	tests := []struct {
+		name      string
+		validate  func(*corev2.Event) (int, error)
+		execute   func(*corev2.Event) (int, error)
+		eventFile string
+		status    int
+		message   string
+	}{
+		{
+			name:      "framework error",
+			validate:  noOp,
+			execute:   noOp,
+			eventFile: "test/event-invalid-json.json",
+			status:    CheckStateUnknown,
+			message:   "failed to unmarshal",
+		},
+		{
+			name:     "returned status",
+			validate: noOp,
+			execute: func(*corev2.Event) (int, error) {
+				return CheckStateCritical, errors.New("disk full")
+			},
+			eventFile: "test/event-no-override.json",
+			status:    CheckStateCritical,
+			message:   "error executing check: disk full",
+		},
+		{
+			name:     "unknown",
+			validate: noOp,
+			execute: func(*corev2.Event) (int, error) {
+				return CheckStateCritical, Unknown(errors.New("missing credentials"))
+			},
+			eventFile: "test/event-no-override.json",
+			status:    CheckStateUnknown,
+			message:   "missing credentials",
+		},
+		{
+			name: "validation warning",
+			validate: func(*corev2.Event) (int, error) {
+				return 1, Warning(errors.New("deprecated flag"))
+			},
+			execute:   noOp,
+			eventFile: "test/event-no-override.json",
+			status:    CheckStateWarning,
+			message:   "error validating input: deprecated flag",
+		},
+		{
+			name:     "transient",
+			validate: noOp,
+			execute: func(*corev2.Event) (int, error) {
+				return 0, Transient(Critical(errors.New("connection refused")))
+			},
+			eventFile: "test/event-no-override.json",
+			status:    CheckStateCritical,
+			message:   "connection refused (transient)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check := NewCheck(&defaultCheckConfig, nil, test.validate, test.execute, true)
+			status, errorStr := checkErrorExecuteUtil(t, check, test.eventFile)
+			assert.Equal(t, test.status, status)
+			assert.Contains(t, errorStr, test.message)
+		})
+	}
+}
+
+func TestCheckSetErrorStatus(t *testing.T) {
+	noOp := func(*corev2.Event) (int, error) { return 0, nil }
+	check := NewCheck(&defaultCheckConfig, nil, noOp, noOp, true)
+	check.SetErrorStatus(CheckStateCritical)
+	status, errorStr := checkErrorExecuteUtil(t, check, "test/event-invalid-json.json")
+	assert.Equal(t, CheckStateCritical, status)
+	assert.True(t, strings.Contains(errorStr, "failed to unmarshal"), errorStr)
+}
+
+func TestHandlerErrorStatus(t *testing.T) {
+	clearEnvironment()
+	status, errorStr := goHandlerExecuteUtil(t, &defaultHandlerConfig, "test/event-no-override.json", nil,
+		func(*corev2.Event) error {
+			return nil
+		}, func(*corev2.Event) error {
+			return Unknown(errors.New("invalid webhook URL"))
+		}, "", 0, false)
+	assert.Equal(t, CheckStateUnknown, status)
+	assert.Contains(t, errorStr, "error executing handler: invalid webhook URL")
+}
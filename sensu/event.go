@@ -2,6 +2,7 @@ package sensu
 
 import (
 	"fmt"
+	"time"
 
 	corev2 "github.com/sensu/core/v2"
 )
@@ -22,16 +23,15 @@ func EventKey(event *corev2.Event) string {
 	return entityName + "/" + checkName
 }
 
-// EventSummaryWithTrim generates the event summary, trimming the output at trimAt if necessary
+// EventSummaryWithTrim generates the event summary, trimming the output at trimAt runes if necessary.
+// SummaryBuilder builds richer summaries, including the check's description.
 func EventSummaryWithTrim(event *corev2.Event, trimAt int) string {
-	// TODO: Ruby code uses check[:notification] and check[:description] which are not present in the GO code.
-	// Skipping them from now.
 	output := nilStr
 	if event != nil && event.Check != nil && len(event.Check.Output) > 0 {
 		output = event.Check.Output
 	}
-	if trimAt > 0 && len(output) > trimAt {
-		output = string(([]rune(output))[0:trimAt])
+	if trimAt > 0 {
+		output = truncate(output, trimAt, false)
 	}
 	return EventKey(event) + " : " + output
 }
@@ -49,3 +49,88 @@ func FormattedMessage(event *corev2.Event) string {
 	}
 	return fmt.Sprintf("%s - %s", action, EventSummary(event))
 }
+
+// IsIncident tells if the event's check is in a non-OK state.
+func IsIncident(event *corev2.Event) bool {
+	return event != nil && event.HasCheck() && event.IsIncident()
+}
+
+// IsResolution tells if the event's check has returned to OK from a non-OK
+// state.
+func IsResolution(event *corev2.Event) bool {
+	return event != nil && event.HasCheck() && event.IsResolution()
+}
+
+// IsStateChange tells if the event's check status differs from the status of
+// its previous execution. A check without a previous execution in its history
+// is treated as if it was OK.
+func IsStateChange(event *corev2.Event) bool {
+	if event == nil || !event.HasCheck() {
+		return false
+	}
+	return int(event.Check.Status) != previousStatus(event.Check)
+}
+
+// IsFlapping tells if the event's check is flapping.
+func IsFlapping(event *corev2.Event) bool {
+	return event != nil && event.HasCheck() && event.Check.State == corev2.EventFlappingState
+}
+
+// IsSilenced tells if the event is silenced.
+func IsSilenced(event *corev2.Event) bool {
+	return event != nil && event.HasCheck() && event.IsSilenced()
+}
+
+// IncidentDuration returns how long the event's check has been in a non-OK
+// state, as of the event's timestamp. It is zero if the check is OK. The start
+// of the incident is the check's last_ok, or, for a check that has never been
+// OK, the first of the non-OK executions at the end of its history.
+func IncidentDuration(event *corev2.Event) time.Duration {
+	if !IsIncident(event) {
+		return 0
+	}
+	check := event.Check
+	now := event.Timestamp
+	if now == 0 {
+		now = check.Executed
+	}
+	start := check.LastOK
+	if start == 0 {
+		for i := len(check.History) - 1; i >= 0 && check.History[i].Status != 0; i-- {
+			start = check.History[i].Executed
+		}
+	}
+	if start == 0 || now <= start {
+		return 0
+	}
+	return time.Unix(now, 0).Sub(time.Unix(start, 0))
+}
+
+// SeverityChange compares the event's check status with the status of its
+// previous execution. It is positive if the status got worse, negative if it
+// improved, and zero if its severity is unchanged. Statuses are ordered from
+// OK, to WARNING, to UNKNOWN, to CRITICAL.
+func SeverityChange(event *corev2.Event) int {
+	if event == nil || !event.HasCheck() {
+		return 0
+	}
+	current := severity(int(event.Check.Status))
+	previous := severity(previousStatus(event.Check))
+	switch {
+	case current > previous:
+		return 1
+	case current < previous:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// previousStatus returns the status of the check's previous execution, the
+// next to last entry of its history, or OK if there is none.
+func previousStatus(check *corev2.Check) int {
+	if len(check.History) < 2 {
+		return CheckStateOK
+	}
+	return int(check.History[len(check.History)-2].Status)
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"testing"
+	"time"
 )
 
 func TestValidEvent_EventKey(t *testing.T) {
@@ -275,3 +276,78 @@ func TestNilEvent_FormattedMessage(t *testing.T) {
 	formattedMessage := FormattedMessage(nil)
 	assert.Equal(t, "ALERT - nil/nil : nil", formattedMessage)
 }
+
+func classifiedEvent(statuses ...uint32) *corev2.Event {
+	event := &corev2.Event{
+		Timestamp: 1000 + int64(len(statuses))*60,
+		Entity:    &corev2.Entity{ObjectMeta: corev2.ObjectMeta{Name: "entity"}},
+		Check:     &corev2.Check{ObjectMeta: corev2.ObjectMeta{Name: "check"}},
+	}
+	for i, status := range statuses {
+		executed := 1000 + int64(i+1)*60
+		event.Check.History = append(event.Check.History, corev2.CheckHistory{Status: status, Executed: executed})
+		event.Check.Status = status
+		event.Check.Executed = executed
+		if status == 0 {
+			event.Check.LastOK = executed
+		}
+	}
+	return event
+}
+
+func TestEventClassification(t *testing.T) {
+	tests := []struct {
+		name        string
+		event       *corev2.Event
+		incident    bool
+		resolution  bool
+		stateChange bool
+		severity    int
+	}{
+		{"nil event", nil, false, false, false, 0},
+		{"no check", &corev2.Event{}, false, false, false, 0},
+		{"first ok", classifiedEvent(0), false, false, false, 0},
+		{"first critical", classifiedEvent(2), true, false, true, 1},
+		{"still ok", classifiedEvent(0, 0), false, false, false, 0},
+		{"new incident", classifiedEvent(0, 1), true, false, true, 1},
+		{"ongoing incident", classifiedEvent(1, 1), true, false, false, 0},
+		{"escalation", classifiedEvent(1, 2), true, false, true, 1},
+		{"unknown to warning", classifiedEvent(3, 1), true, false, true, -1},
+		{"critical to unknown", classifiedEvent(2, 3), true, false, true, -1},
+		{"resolution", classifiedEvent(2, 0), false, true, true, -1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.incident, IsIncident(test.event))
+			assert.Equal(t, test.resolution, IsResolution(test.event))
+			assert.Equal(t, test.stateChange, IsStateChange(test.event))
+			assert.Equal(t, test.severity, SeverityChange(test.event))
+		})
+	}
+}
+
+func TestIsFlappingAndSilenced(t *testing.T) {
+	event := classifiedEvent(0, 2)
+	assert.False(t, IsFlapping(event))
+	assert.False(t, IsSilenced(event))
+	event.Check.State = corev2.EventFlappingState
+	event.Check.Silenced = []string{"entity:entity:*"}
+	assert.True(t, IsFlapping(event))
+	assert.True(t, IsSilenced(event))
+	assert.False(t, IsFlapping(nil))
+	assert.False(t, IsSilenced(nil))
+}
+
+func TestIncidentDuration(t *testing.T) {
+	assert.Equal(t, time.Duration(0), IncidentDuration(nil))
+	assert.Equal(t, time.Duration(0), IncidentDuration(classifiedEvent(1, 0)))
+
+	// last_ok is the start of the incident
+	assert.Equal(t, 2*time.Minute, IncidentDuration(classifiedEvent(0, 2, 2)))
+
+	// without last_ok, the history is used
+	event := classifiedEvent(2, 2, 1)
+	assert.Equal(t, 2*time.Minute, IncidentDuration(event))
+	event.Timestamp = 0
+	assert.Equal(t, 2*time.Minute, IncidentDuration(event))
+}
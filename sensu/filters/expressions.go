@@ -0,0 +1,158 @@
+package filters
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	corev2 "github.com/sensu/core/v2"
+)
+
+// expressionFilter evaluates the JavaScript expressions of a Sensu event
+// filter, as the Sensu backend does. The event is available to the
+// expressions as event, with the field names of its JSON representation, and
+// the hour and weekday functions are defined.
+type expressionFilter struct {
+	action      string
+	expressions []string
+	scripts     []*otto.Script
+
+	// the VM can't be used concurrently
+	mu sync.Mutex
+	vm *otto.Otto
+}
+
+// AllowExpressions returns a filter that only allows events for which all of
+// the expressions are true, like a Sensu filter with the allow action. An
+// expression that fails to evaluate filters the event out.
+func AllowExpressions(expressions ...string) (Filter, error) {
+	return newExpressionFilter(corev2.EventFilterActionAllow, expressions)
+}
+
+// DenyExpressions returns a filter that filters out events for which all of
+// the expressions are true, like a Sensu filter with the deny action. An
+// expression that fails to evaluate lets the event through.
+func DenyExpressions(expressions ...string) (Filter, error) {
+	return newExpressionFilter(corev2.EventFilterActionDeny, expressions)
+}
+
+func newExpressionFilter(action string, expressions []string) (*expressionFilter, error) {
+	vm := otto.New()
+	if err := addTimeFuncs(vm); err != nil {
+		return nil, err
+	}
+	f := &expressionFilter{action: action, expressions: expressions, vm: vm}
+	for i, expression := range expressions {
+		script, err := vm.Compile("", expression)
+		if err != nil {
+			return nil, fmt.Errorf("syntax error in %s expression %d (%s): %s", action, i, expression, err)
+		}
+		f.scripts = append(f.scripts, script)
+	}
+	return f, nil
+}
+
+// Allow evaluates the filter's expressions against event.
+func (f *expressionFilter) Allow(event *corev2.Event) (bool, string) {
+	if len(f.scripts) == 0 {
+		return true, ""
+	}
+	matched, expression, err := f.match(event)
+	if f.action == corev2.EventFilterActionAllow {
+		if err != nil {
+			return false, fmt.Sprintf("error evaluating allow expression (%s): %s", expression, err)
+		}
+		if !matched {
+			return false, fmt.Sprintf("allow expression is false (%s)", expression)
+		}
+		return true, ""
+	}
+	if err == nil && matched {
+		return false, fmt.Sprintf("deny expressions are true (%v)", f.expressions)
+	}
+	return true, ""
+}
+
+// match tells if all of the expressions are true. Otherwise, it returns the
+// first expression that isn't, and the error evaluating it if there was one.
+func (f *expressionFilter) match(event *corev2.Event) (bool, string, error) {
+	parameters, err := synthesize(event)
+	if err != nil {
+		return false, "", err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.vm.Set("event", parameters); err != nil {
+		return false, "", err
+	}
+	for i, script := range f.scripts {
+		value, err := f.vm.Run(script)
+		if err != nil {
+			return false, f.expressions[i], err
+		}
+		ok, err := value.ToBoolean()
+		if err != nil {
+			return false, f.expressions[i], err
+		}
+		if !ok {
+			return false, f.expressions[i], nil
+		}
+	}
+	return true, "", nil
+}
+
+// synthesize converts event to the generic form used by the expressions,
+// with the field names of its JSON representation.
+func synthesize(event *corev2.Event) (map[string]interface{}, error) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// addTimeFuncs defines the hour and weekday functions, which the Sensu
+// backend provides to filter expressions.
+func addTimeFuncs(vm *otto.Otto) error {
+	funcs := map[string]interface{}{
+		// hour returns the hour within the day, in UTC
+		"hour": func(args ...interface{}) interface{} {
+			if len(args) == 0 {
+				return 0
+			}
+			return time.Unix(toInt64(args[0]), 0).UTC().Hour()
+		},
+		// weekday returns the day of the week, where Sunday is 0
+		"weekday": func(args ...interface{}) interface{} {
+			if len(args) == 0 {
+				return 0
+			}
+			return int(time.Unix(toInt64(args[0]), 0).UTC().Weekday())
+		},
+	}
+	for name, fn := range funcs {
+		if err := vm.Set(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toInt64(v interface{}) int64 {
+	switch v := v.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	default:
+		return 0
+	}
+}
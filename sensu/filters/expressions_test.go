@@ -0,0 +1,50 @@
+package filters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowExpressions(t *testing.T) {
+	filter, err := AllowExpressions("event.check.occurrences > 3", "event.entity.metadata.name == 'entity'")
+	if !assert.NoError(t, err) {
+		return
+	}
+	ok, _ := filter.Allow(incident(2, 4, 4))
+	assert.True(t, ok)
+	ok, reason := filter.Allow(incident(2, 2, 2))
+	assert.False(t, ok)
+	assert.Equal(t, "allow expression is false (event.check.occurrences > 3)", reason)
+
+	// an expression that fails to evaluate filters the event out
+	filter, err = AllowExpressions("event.nothing.here > 1")
+	assert.NoError(t, err)
+	ok, reason = filter.Allow(incident(2, 4, 4))
+	assert.False(t, ok)
+	assert.Contains(t, reason, "error evaluating allow expression")
+}
+
+func TestDenyExpressions(t *testing.T) {
+	filter, err := DenyExpressions("event.check.status == 0", "weekday(event.timestamp) >= 0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	ok, reason := filter.Allow(incident(0, 1, 1))
+	assert.False(t, ok)
+	assert.Contains(t, reason, "deny expressions are true")
+	ok, _ = filter.Allow(incident(2, 1, 1))
+	assert.True(t, ok)
+
+	// an expression that fails to evaluate lets the event through
+	filter, err = DenyExpressions("event.nothing.here > 1")
+	assert.NoError(t, err)
+	ok, _ = filter.Allow(incident(0, 1, 1))
+	assert.True(t, ok)
+}
+
+func TestExpressionSyntaxError(t *testing.T) {
+	_, err := AllowExpressions("event.check.status ==")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "syntax error in allow expression 0")
+}
@@ -0,0 +1,108 @@
+// Package filters implements Sensu's built-in event filters, and fatigue
+// filtering, for handlers that run outside of the Sensu backend's pipeline.
+// The filters only depend on the event.
+package filters
+
+import (
+	"fmt"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// Filter decides if an event is handled.
+type Filter interface {
+	// Allow tells if event should be handled. If it shouldn't, reason says
+	// why.
+	Allow(event *corev2.Event) (ok bool, reason string)
+}
+
+// FilterFunc is a function used as a Filter.
+type FilterFunc func(event *corev2.Event) (ok bool, reason string)
+
+// Allow calls f.
+func (f FilterFunc) Allow(event *corev2.Event) (bool, string) {
+	return f(event)
+}
+
+var (
+	// IsIncident is Sensu's is_incident filter. It only allows incidents,
+	// where the check's status is not OK, and resolutions.
+	IsIncident Filter = FilterFunc(func(event *corev2.Event) (bool, string) {
+		if event.IsIncident() || event.IsResolution() {
+			return true, ""
+		}
+		return false, "not an incident or resolution"
+	})
+
+	// NotSilenced is Sensu's not_silenced filter. It only allows events that
+	// are not silenced.
+	NotSilenced Filter = FilterFunc(func(event *corev2.Event) (bool, string) {
+		if event.IsSilenced() {
+			return false, "silenced"
+		}
+		return true, ""
+	})
+
+	// HasMetrics is Sensu's has_metrics filter. It only allows events that
+	// have metrics.
+	HasMetrics Filter = FilterFunc(func(event *corev2.Event) (bool, string) {
+		if event.HasMetrics() {
+			return true, ""
+		}
+		return false, "no metrics"
+	})
+)
+
+// Fatigue returns a filter that limits how often an incident is handled. The
+// incident is first handled when it reaches occurrences, and then again
+// every refresh, based on the check's interval. A refresh of zero, or a check
+// without an interval, handles the incident only once. A resolution is
+// handled if the incident it resolves was.
+func Fatigue(occurrences int64, refresh time.Duration) Filter {
+	return FilterFunc(func(event *corev2.Event) (bool, string) {
+		if !event.HasCheck() {
+			return false, "no check"
+		}
+		check := event.Check
+		if event.IsResolution() {
+			if check.OccurrencesWatermark >= occurrences {
+				return true, ""
+			}
+			return false, fmt.Sprintf("resolution of an incident that wasn't handled (%d of %d occurrences)", check.OccurrencesWatermark, occurrences)
+		}
+		if !event.IsIncident() {
+			return false, "not an incident"
+		}
+		if check.Occurrences == occurrences {
+			return true, ""
+		}
+		if check.Occurrences < occurrences {
+			return false, fmt.Sprintf("%d of %d occurrences", check.Occurrences, occurrences)
+		}
+		if refresh > 0 && check.Interval > 0 {
+			every := int64(refresh / (time.Duration(check.Interval) * time.Second))
+			if every < 1 {
+				every = 1
+			}
+			if (check.Occurrences-occurrences)%every == 0 {
+				return true, ""
+			}
+		}
+		return false, fmt.Sprintf("already handled, occurrence %d", check.Occurrences)
+	})
+}
+
+// Run runs the filters on event, in order. If one of them filters the event
+// out, it returns false and the reason.
+func Run(event *corev2.Event, filters ...Filter) (bool, string) {
+	for _, filter := range filters {
+		if filter == nil {
+			continue
+		}
+		if ok, reason := filter.Allow(event); !ok {
+			return false, reason
+		}
+	}
+	return true, ""
+}
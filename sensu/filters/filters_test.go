@@ -0,0 +1,85 @@
+package filters
+
+import (
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func incident(status uint32, occurrences, watermark int64, history ...uint32) *corev2.Event {
+	event := corev2.FixtureEvent("entity", "check")
+	event.Check.Status = status
+	event.Check.Occurrences = occurrences
+	event.Check.OccurrencesWatermark = watermark
+	event.Check.Interval = 60
+	event.Check.History = nil
+	for _, s := range append(history, status) {
+		event.Check.History = append(event.Check.History, corev2.CheckHistory{Status: s})
+	}
+	return event
+}
+
+func TestIsIncident(t *testing.T) {
+	ok, _ := IsIncident.Allow(incident(2, 1, 1, 0))
+	assert.True(t, ok)
+	ok, _ = IsIncident.Allow(incident(0, 1, 3, 2))
+	assert.True(t, ok, "resolution")
+	ok, reason := IsIncident.Allow(incident(0, 5, 5, 0))
+	assert.False(t, ok)
+	assert.Equal(t, "not an incident or resolution", reason)
+}
+
+func TestNotSilenced(t *testing.T) {
+	event := incident(2, 1, 1)
+	ok, _ := NotSilenced.Allow(event)
+	assert.True(t, ok)
+	event.Check.Silenced = []string{"entity:entity:*"}
+	ok, reason := NotSilenced.Allow(event)
+	assert.False(t, ok)
+	assert.Equal(t, "silenced", reason)
+}
+
+func TestHasMetrics(t *testing.T) {
+	event := incident(0, 1, 1)
+	ok, _ := HasMetrics.Allow(event)
+	assert.False(t, ok)
+	event.Metrics = &corev2.Metrics{Points: []*corev2.MetricPoint{{Name: "load", Value: 1}}}
+	ok, _ = HasMetrics.Allow(event)
+	assert.True(t, ok)
+}
+
+func TestFatigue(t *testing.T) {
+	// handled on the third occurrence, then every five minutes of a one minute interval
+	filter := Fatigue(3, 5*time.Minute)
+	var handled []int64
+	for occurrences := int64(1); occurrences <= 14; occurrences++ {
+		if ok, _ := filter.Allow(incident(2, occurrences, occurrences, 2)); ok {
+			handled = append(handled, occurrences)
+		}
+	}
+	assert.Equal(t, []int64{3, 8, 13}, handled)
+
+	ok, _ := filter.Allow(incident(0, 1, 4, 2))
+	assert.True(t, ok, "resolution of a handled incident")
+	ok, reason := filter.Allow(incident(0, 1, 2, 2))
+	assert.False(t, ok)
+	assert.Contains(t, reason, "wasn't handled")
+
+	once := Fatigue(1, 0)
+	ok, _ = once.Allow(incident(2, 1, 1, 0))
+	assert.True(t, ok)
+	ok, _ = once.Allow(incident(2, 2, 2, 2))
+	assert.False(t, ok)
+}
+
+func TestRun(t *testing.T) {
+	event := incident(0, 5, 5, 0)
+	ok, reason := Run(event, NotSilenced, IsIncident, HasMetrics)
+	assert.False(t, ok)
+	assert.Equal(t, "not an incident or resolution", reason)
+
+	ok, _ = Run(event, NotSilenced, nil)
+	assert.True(t, ok)
+}
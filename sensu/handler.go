@@ -1,13 +1,19 @@
 package sensu
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
+	"sync"
 
 	corev2 "github.com/sensu/core/v2"
 	"github.com/sensu/sensu-licensing/v2/api/licensing"
+	"github.com/sensu/sensu-plugin-sdk/httpclient"
+	"github.com/sensu/sensu-plugin-sdk/sensu/filters"
 )
 
 // Handler is a framework for writing Sensu handlers.
@@ -16,15 +22,28 @@ type Handler struct {
 	validationFunction func(event *corev2.Event) error
 	executeFunction    func(event *corev2.Event) error
 	enterprise         bool
+	dryRun             bool
+	filters            []filters.Filter
+	allowExpressions   []string
+	denyExpressions    []string
+	retryPolicy        RetryPolicy
+	spool              *spool
+
+	// expression filters are compiled once for all of the events handled
+	expressionMu      sync.Mutex
+	expressionFilters []filters.Filter
+	expressionSource  string
 }
 
 // GoHandler is a framework for writing Sensu handlers.
 // Deprecated: use Handler instead.
 type GoHandler = Handler
 
-// NewHandler creates a new handler.
+// NewHandler creates a new handler. Events that any of the filters filter out
+// are not handled; the handler logs why, and exits with status 0.
 func NewHandler(config *PluginConfig, options []ConfigOption,
-	validationFunction func(event *corev2.Event) error, executeFunction func(event *corev2.Event) error) *Handler {
+	validationFunction func(event *corev2.Event) error, executeFunction func(event *corev2.Event) error,
+	eventFilters ...filters.Filter) *Handler {
 	handler := &Handler{
 		framework: pluginFramework{
 			config:                 config,
@@ -39,12 +58,16 @@ func NewHandler(config *PluginConfig, options []ConfigOption,
 		},
 		validationFunction: validationFunction,
 		executeFunction:    executeFunction,
+		filters:            eventFilters,
 	}
 
 	handler.framework.SetWorkflow(handler.workflow)
+	handler.framework.SetValidation(handler.validate)
+	handler.framework.SetEventFunction(handler.handle)
 	if err := handler.framework.Init(); err != nil {
 		log.Printf("failed to initialize handler plugin: %s", err)
 	}
+	handler.setupFlags()
 
 	return handler
 }
@@ -55,7 +78,8 @@ var NewGoHandler = NewHandler
 
 // NewEnterpriseHandler is like NewHandler, but requires a valid license.
 func NewEnterpriseHandler(config *PluginConfig, options []ConfigOption,
-	validationFunction func(event *corev2.Event) error, executeFunction func(event *corev2.Event) error) *Handler {
+	validationFunction func(event *corev2.Event) error, executeFunction func(event *corev2.Event) error,
+	eventFilters ...filters.Filter) *Handler {
 	handler := &Handler{
 		framework: pluginFramework{
 			config:                 config,
@@ -69,12 +93,16 @@ func NewEnterpriseHandler(config *PluginConfig, options []ConfigOption,
 		validationFunction: validationFunction,
 		executeFunction:    executeFunction,
 		enterprise:         true,
+		filters:            eventFilters,
 	}
 
 	handler.framework.SetWorkflow(handler.workflow)
+	handler.framework.SetValidation(handler.validate)
+	handler.framework.SetEventFunction(handler.handle)
 	if err := handler.framework.Init(); err != nil {
 		log.Printf("failed to initialize handler plugin: %s", err)
 	}
+	handler.setupFlags()
 
 	return handler
 }
@@ -82,35 +110,183 @@ func NewEnterpriseHandler(config *PluginConfig, options []ConfigOption,
 // NewEnterpriseGoHandler is deprecated, use NewEnterpriseHandler
 var NewEnterpriseGoHandler = NewEnterpriseHandler
 
+// setupFlags adds the flags that are specific to handlers.
+func (h *Handler) setupFlags() {
+	cmd := h.framework.cmd
+	if cmd == nil {
+		return
+	}
+	if cmd.Flags().Lookup("dry-run") == nil {
+		cmd.Flags().BoolVar(&h.dryRun, "dry-run", false, "Run the handler without side effects, logging outbound requests instead of sending them")
+	}
+	if cmd.Flags().Lookup("allow-filter") == nil {
+		cmd.Flags().StringArrayVar(&h.allowExpressions, "allow-filter", nil, "A Sensu filter expression that events must match to be handled. Can be repeated; all of the expressions must match")
+	}
+	if cmd.Flags().Lookup("deny-filter") == nil {
+		cmd.Flags().StringArrayVar(&h.denyExpressions, "deny-filter", nil, "A Sensu filter expression that keeps events from being handled. Can be repeated; all of the expressions must match")
+	}
+	if serve := h.framework.serveCmd; serve != nil {
+		for _, name := range []string{"dry-run", "allow-filter", "deny-filter"} {
+			if serve.Flags().Lookup(name) == nil {
+				serve.Flags().AddFlag(cmd.Flags().Lookup(name))
+			}
+		}
+	}
+	h.framework.setupBatchFlags(cmd)
+}
+
+// DryRun reports whether the handler is being run with --dry-run. Execute
+// functions should consult it before doing anything with side effects that
+// isn't covered by Transport.
+func (h *Handler) DryRun() bool {
+	return h.dryRun
+}
+
+// Transport wraps next so that, when the handler is run with --dry-run,
+// requests are logged by an httpclient.DryRunTransport instead of being sent.
+// If next is nil, http.DefaultTransport is used. Whether the handler is in
+// dry-run mode is decided for each request, so the transport can be created
+// before the command line is parsed.
+func (h *Handler) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &dryRunSwitch{
+		handler: h,
+		next:    next,
+		dryRun:  new(httpclient.DryRunTransport),
+	}
+}
+
+type dryRunSwitch struct {
+	handler *Handler
+	next    http.RoundTripper
+	dryRun  *httpclient.DryRunTransport
+}
+
+func (d *dryRunSwitch) RoundTrip(req *http.Request) (*http.Response, error) {
+	if d.handler.DryRun() {
+		return d.dryRun.RoundTrip(req)
+	}
+	return d.next.RoundTrip(req)
+}
+
+// Validates the handler's input and its --allow-filter and --deny-filter
+// expressions
+func (h *Handler) validate(_ []string) (int, error) {
+	var problems validationErrors
+	status, err := h.validateInput(h.framework.GetStdinEvent())
+	if err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := h.compileExpressionFilters(); err != nil {
+		status = 1
+		problems = append(problems, err)
+	}
+	if len(problems) > 0 {
+		return status, problems
+	}
+	return 0, nil
+}
+
+func (h *Handler) validateInput(event *corev2.Event) (int, error) {
+	// Validate input using validateFunction
+	err := h.validationFunction(event)
+	if err != nil {
+		return 1, validationFailed(err)
+	}
+	return 0, nil
+}
+
 // Executes the handler's workflow
 func (h *Handler) workflow(_ []string) (int, error) {
+	if h.dryRun {
+		log.Println("dry run: outbound requests made through the handler's transport will not be sent")
+	}
 	event := h.framework.GetStdinEvent()
+	if ok, status, err := h.accept(event); !ok {
+		return status, err
+	}
+
+	// spooled events are older than the event from stdin, so they are
+	// handled first
+	if h.spool != nil && !h.dryRun {
+		// spooled events don't get the overrides of the event from stdin
+		overrides := h.framework.snapshotOptions()
+		restoreOptions(h.framework.commandLineOptions)
+		_, err := h.flushSpool(h.framework.context(), h.spool.options.FlushLimit)
+		restoreOptions(overrides)
+		if err != nil {
+			log.Printf("spooled events will be handled again later: %s", err)
+		}
+		// handling the event now would overtake the older events for the
+		// same check that are left in the spool
+		if h.spool.holds(EventKey(event)) {
+			h.spoolEvent(event)
+			return 1, fmt.Errorf("event %s was spooled behind older events for the same check", EventKey(event))
+		}
+	}
+	return h.deliver(context.Background(), event)
+}
+
+// handle validates and handles a single event. It is used for the event read
+// from stdin, as well as for each event in batch and serve mode. Retries of
+// the execute function stop when ctx is done.
+func (h *Handler) handle(ctx context.Context, event *corev2.Event, _ io.Writer) (int, error) {
+	if ok, status, err := h.accept(event); !ok {
+		return status, err
+	}
+	return h.deliver(ctx, event)
+}
+
+// accept checks the handler's license, validates the event, and runs it
+// through the filters. It returns false, with the status to exit with, if the
+// event isn't to be handled.
+func (h *Handler) accept(event *corev2.Event) (bool, int, error) {
 	if h.enterprise {
 		var licenseFile *licensing.LicenseFile
 		license := os.Getenv("SENSU_LICENSE_FILE")
 		if license == "" {
-			return 1, fmt.Errorf("valid sensu license is required to execute")
+			return false, 1, fmt.Errorf("valid sensu license is required to execute")
 		}
 		err := json.Unmarshal([]byte(license), &licenseFile)
 		if err != nil {
-			return 1, fmt.Errorf("error reading license file: %s", err)
+			return false, 1, fmt.Errorf("error reading license file: %s", err)
 		}
 		err = licenseFile.Validate()
 		if err != nil {
-			return 1, fmt.Errorf("error validating license file: %s", err)
+			return false, 1, fmt.Errorf("error validating license file: %s", err)
 		}
 	}
 
-	// Validate input using validateFunction
-	err := h.validationFunction(event)
+	if status, err := h.validateInput(event); err != nil {
+		return false, status, err
+	}
+
+	expressionFilters, err := h.compileExpressionFilters()
 	if err != nil {
-		return 1, ErrValidationFailed(err.Error())
+		return false, 1, err
+	}
+	ok, reason := filters.Run(event, h.filters...)
+	if ok {
+		ok, reason = filters.Run(event, expressionFilters...)
 	}
+	if !ok {
+		log.Printf("event %s was filtered: %s", EventKey(event), reason)
+		return false, 0, nil
+	}
+	return true, 0, nil
+}
 
-	// Execute handler logic using executeFunction
-	err = h.executeFunction(event)
+// deliver runs the execute function for the event, retrying transient errors.
+// The event is spooled if they persist.
+func (h *Handler) deliver(ctx context.Context, event *corev2.Event) (int, error) {
+	err := h.execute(ctx, event)
 	if err != nil {
-		return 1, fmt.Errorf("error executing handler: %s", err)
+		if IsTransient(err) {
+			h.spoolEvent(event)
+		}
+		return 1, fmt.Errorf("error executing handler: %w", err)
 	}
 
 	return 0, nil
@@ -120,3 +296,35 @@ func (h *Handler) workflow(_ []string) (int, error) {
 func (h *Handler) Execute() {
 	h.framework.Execute()
 }
+
+func (h *Handler) getFramework() *pluginFramework {
+	return &h.framework
+}
+
+// compileExpressionFilters returns the filters for the --allow-filter and
+// --deny-filter expressions.
+func (h *Handler) compileExpressionFilters() ([]filters.Filter, error) {
+	h.expressionMu.Lock()
+	defer h.expressionMu.Unlock()
+	source := fmt.Sprintf("%q %q", h.allowExpressions, h.denyExpressions)
+	if source == h.expressionSource {
+		return h.expressionFilters, nil
+	}
+	var compiled []filters.Filter
+	if len(h.allowExpressions) > 0 {
+		allow, err := filters.AllowExpressions(h.allowExpressions...)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, allow)
+	}
+	if len(h.denyExpressions) > 0 {
+		deny, err := filters.DenyExpressions(h.denyExpressions...)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, deny)
+	}
+	h.expressionFilters, h.expressionSource = compiled, source
+	return compiled, nil
+}
@@ -4,11 +4,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu/filters"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -975,3 +980,124 @@ func TestNewGoHandlerEnterprise(t *testing.T) {
 	goHandler.Execute()
 	assert.Equal(t, 1, exitStatus)
 }
+
+func TestHandlerDryRun(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	var handler *Handler
+	var dryRun bool
+	noOp := func(*corev2.Event) error { return nil }
+	handler = NewHandler(&defaultHandlerConfig, nil, noOp, func(event *corev2.Event) error {
+		dryRun = handler.DryRun()
+		client := http.Client{Transport: handler.Transport(nil)}
+		resp, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	})
+
+	var exitStatus = -99
+	handler.framework.eventReader = getFileReader("test/event-check-override.json")
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.framework.cmd.SetArgs([]string{"--dry-run"})
+	handler.Execute()
+
+	assert.Equal(t, 0, exitStatus)
+	assert.True(t, dryRun)
+	assert.Equal(t, 0, requests)
+
+	handler.dryRun = false
+	handler.framework.eventReader = getFileReader("test/event-check-override.json")
+	handler.framework.cmd.SetArgs([]string{})
+	handler.Execute()
+
+	assert.Equal(t, 0, exitStatus)
+	assert.False(t, dryRun)
+	assert.Equal(t, 1, requests)
+}
+
+func TestHandlerFilters(t *testing.T) {
+	clearEnvironment()
+	var executed bool
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, nil, noOp, func(event *corev2.Event) error {
+		executed = true
+		return nil
+	}, filters.NotSilenced, filters.HasMetrics)
+
+	var exitStatus = -99
+	handler.framework.eventReader = getFileReader("test/event-no-override.json")
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.framework.cmd.SetArgs([]string{})
+	handler.Execute()
+
+	assert.Equal(t, 0, exitStatus)
+	assert.False(t, executed)
+
+	// the event is an incident, so it is handled
+	handler.filters = []filters.Filter{filters.IsIncident}
+	handler.framework.eventReader = getFileReader("test/event-no-override.json")
+	handler.Execute()
+	assert.Equal(t, 0, exitStatus)
+	assert.True(t, executed)
+}
+
+func TestHandlerExpressionFilters(t *testing.T) {
+	clearEnvironment()
+	var executed bool
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, nil, noOp, func(event *corev2.Event) error {
+		executed = true
+		return nil
+	})
+
+	var exitStatus = -99
+	var errorStr string
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.framework.errorLogFunction = func(format string, a ...interface{}) {
+		errorStr = fmt.Sprintf(format, a...)
+	}
+
+	handler.framework.eventReader = getFileReader("test/event-no-override.json")
+	handler.framework.cmd.SetArgs([]string{"--allow-filter", "event.check.occurrences > 3"})
+	handler.Execute()
+	assert.Equal(t, 0, exitStatus)
+	assert.False(t, executed)
+
+	resetExpressionFlags(handler)
+	handler.framework.eventReader = getFileReader("test/event-no-override.json")
+	handler.framework.cmd.SetArgs([]string{"--allow-filter", "event.check.status == 1", "--deny-filter", "event.check.occurrences > 3"})
+	handler.Execute()
+	assert.Equal(t, 0, exitStatus)
+	assert.True(t, executed)
+
+	executed = false
+	resetExpressionFlags(handler)
+	handler.framework.eventReader = getFileReader("test/event-no-override.json")
+	handler.framework.cmd.SetArgs([]string{"--allow-filter", "event.check.status ==", "--deny-filter", "false"})
+	handler.Execute()
+	assert.Equal(t, 1, exitStatus)
+	assert.Contains(t, errorStr, "syntax error")
+	assert.False(t, executed)
+}
+
+// resetExpressionFlags clears the repeatable filter flags, which otherwise
+// accumulate values across executions of the same command.
+func resetExpressionFlags(handler *Handler) {
+	for _, name := range []string{"allow-filter", "deny-filter"} {
+		flag := handler.framework.cmd.Flags().Lookup(name)
+		_ = flag.Value.(pflag.SliceValue).Replace(nil)
+		flag.Changed = false
+	}
+}
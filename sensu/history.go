@@ -0,0 +1,104 @@
+package sensu
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// Sparkline characters for each check status.
+const (
+	sparkOK       = '▁'
+	sparkWarning  = '▄'
+	sparkCritical = '█'
+	sparkUnknown  = '▆'
+)
+
+// PercentStateChange returns the percent state change of a check's history,
+// oldest execution first, weighted as Sensu's flap detection does: recent
+// state changes weigh more than older ones. Sensu only computes it for a full
+// history of 21 executions; shorter histories are accepted here, with the
+// weights spread over the executions they have. A history with fewer than two
+// executions has no state change.
+func PercentStateChange(history []corev2.CheckHistory) float64 {
+	transitions := len(history) - 1
+	if transitions < 1 {
+		return 0
+	}
+	var changes float64
+	for i := 1; i < len(history); i++ {
+		if history[i].Status != history[i-1].Status {
+			changes += 0.8 + 0.4*float64(i-1)/float64(transitions)
+		}
+	}
+	return changes / float64(transitions) * 100
+}
+
+// TimeInState returns how long the check has had its current status, as of
+// its last execution. It is measured from the first of the executions with
+// that status at the end of the check's history.
+func TimeInState(check *corev2.Check) time.Duration {
+	if check == nil {
+		return 0
+	}
+	start := check.Executed
+	for i := len(check.History) - 1; i >= 0 && check.History[i].Status == check.Status; i-- {
+		start = check.History[i].Executed
+	}
+	if start == 0 || check.Executed <= start {
+		return 0
+	}
+	return time.Unix(check.Executed, 0).Sub(time.Unix(start, 0))
+}
+
+// FailuresInLast returns how many of the last n executions in the history
+// were not OK. If n is zero or negative, the whole history is counted.
+func FailuresInLast(history []corev2.CheckHistory, n int) int {
+	if n > 0 && n < len(history) {
+		history = history[len(history)-n:]
+	}
+	var failures int
+	for _, entry := range history {
+		if entry.Status != 0 {
+			failures++
+		}
+	}
+	return failures
+}
+
+// Sparkline renders the history as one character per execution, oldest
+// first, taller for more severe statuses: ▁ is OK, ▄ is WARNING, ▆ is UNKNOWN
+// and █ is CRITICAL.
+func Sparkline(history []corev2.CheckHistory) string {
+	var b strings.Builder
+	for _, entry := range history {
+		switch entry.Status {
+		case CheckStateOK:
+			b.WriteRune(sparkOK)
+		case CheckStateWarning:
+			b.WriteRune(sparkWarning)
+		case CheckStateCritical:
+			b.WriteRune(sparkCritical)
+		default:
+			b.WriteRune(sparkUnknown)
+		}
+	}
+	return b.String()
+}
+
+// HistorySummary summarizes a check's history for notifications, for example
+// "▁▁▄██ 3 of 5 failed, 48% state change, CRITICAL for 1m0s".
+func HistorySummary(check *corev2.Check) string {
+	if check == nil || len(check.History) == 0 {
+		return "no history"
+	}
+	summary := fmt.Sprintf("%s %d of %d failed, %.0f%% state change",
+		Sparkline(check.History), FailuresInLast(check.History, 0), len(check.History),
+		PercentStateChange(check.History))
+	if d := TimeInState(check); d > 0 {
+		summary += fmt.Sprintf(", %s for %s", StatusName(int(check.Status)), d)
+	}
+	return summary
+}
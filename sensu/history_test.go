@@ -0,0 +1,62 @@
+package sensu
+
+import (
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func history(statuses ...uint32) []corev2.CheckHistory {
+	var history []corev2.CheckHistory
+	for i, status := range statuses {
+		history = append(history, corev2.CheckHistory{Status: status, Executed: 1000 + int64(i)*60})
+	}
+	return history
+}
+
+func TestPercentStateChange(t *testing.T) {
+	assert.Equal(t, 0.0, PercentStateChange(nil))
+	assert.Equal(t, 0.0, PercentStateChange(history(2)))
+	assert.Equal(t, 0.0, PercentStateChange(history(0, 0, 0)))
+
+	// matches Sensu's flap detection for a full history
+	var alternating, oneRecent, oneOld []uint32
+	for i := 0; i < 21; i++ {
+		alternating = append(alternating, uint32(i%2))
+		oneRecent = append(oneRecent, 0)
+		oneOld = append(oneOld, 1)
+	}
+	oneRecent[20] = 2
+	oneOld[0] = 0
+	assert.InDelta(t, 99.0, PercentStateChange(history(alternating...)), 0.0001)
+	assert.InDelta(t, 5.9, PercentStateChange(history(oneRecent...)), 0.0001)
+	assert.InDelta(t, 4.0, PercentStateChange(history(oneOld...)), 0.0001)
+}
+
+func TestTimeInState(t *testing.T) {
+	assert.Equal(t, time.Duration(0), TimeInState(nil))
+
+	check := &corev2.Check{History: history(0, 2, 2, 2), Status: 2, Executed: 1180}
+	assert.Equal(t, 2*time.Minute, TimeInState(check))
+
+	check = &corev2.Check{History: history(2, 0), Status: 0, Executed: 1060}
+	assert.Equal(t, time.Duration(0), TimeInState(check))
+}
+
+func TestFailuresInLast(t *testing.T) {
+	h := history(2, 2, 0, 1, 0, 3)
+	assert.Equal(t, 4, FailuresInLast(h, 0))
+	assert.Equal(t, 2, FailuresInLast(h, 3))
+	assert.Equal(t, 4, FailuresInLast(h, 100))
+	assert.Equal(t, 0, FailuresInLast(nil, 3))
+}
+
+func TestHistorySummary(t *testing.T) {
+	assert.Equal(t, "▁▄█▆", Sparkline(history(0, 1, 2, 3)))
+	assert.Equal(t, "no history", HistorySummary(&corev2.Check{}))
+
+	check := &corev2.Check{History: history(0, 0, 1, 2, 2), Status: 2, Executed: 1240}
+	assert.Equal(t, "▁▁▄██ 3 of 5 failed, 48% state change, CRITICAL for 1m0s", HistorySummary(check))
+}
@@ -0,0 +1,186 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// Tags that hold the parts of a perfdata entry other than its label and value.
+// ParsePerfdata sets them, and ToPerfdata renders them.
+const (
+	PerfdataUOMTag      = "perfdata_uom"
+	PerfdataWarningTag  = "perfdata_warn"
+	PerfdataCriticalTag = "perfdata_crit"
+	PerfdataMinTag      = "perfdata_min"
+	PerfdataMaxTag      = "perfdata_max"
+)
+
+// ToPerfdata renders Points as Nagios performance data, with one
+// label=value[UOM];warn;crit;min;max entry per point, separated by spaces.
+// The UOM, min and max come from the point's perfdata tags. The warning and
+// critical thresholds do too if the point has them, otherwise they are set
+// from warning and critical, which may be nil. They are usually
+// thresholds.Range values.
+func (m Points) ToPerfdata(warning, critical fmt.Stringer) string {
+	entries := make([]string, 0, len(m))
+	for _, point := range m {
+		if point == nil {
+			continue
+		}
+		tags := map[string]string{
+			PerfdataWarningTag:  stringOrEmpty(warning),
+			PerfdataCriticalTag: stringOrEmpty(critical),
+		}
+		for _, tag := range point.Tags {
+			switch tag.Name {
+			case PerfdataUOMTag, PerfdataWarningTag, PerfdataCriticalTag, PerfdataMinTag, PerfdataMaxTag:
+				tags[tag.Name] = tag.Value
+			}
+		}
+		fields := []string{
+			strconv.FormatFloat(point.Value, 'f', -1, 64) + tags[PerfdataUOMTag],
+			tags[PerfdataWarningTag],
+			tags[PerfdataCriticalTag],
+			tags[PerfdataMinTag],
+			tags[PerfdataMaxTag],
+		}
+		for len(fields) > 1 && fields[len(fields)-1] == "" {
+			fields = fields[:len(fields)-1]
+		}
+		entries = append(entries, perfdataLabel(point.Name)+"="+strings.Join(fields, ";"))
+	}
+	return strings.Join(entries, " ")
+}
+
+func stringOrEmpty(s fmt.Stringer) string {
+	if s == nil {
+		return ""
+	}
+	return s.String()
+}
+
+// perfdataLabel quotes label if it holds characters that aren't allowed in an
+// unquoted perfdata label.
+func perfdataLabel(label string) string {
+	if !strings.ContainsAny(label, " '=") {
+		return label
+	}
+	return "'" + strings.ReplaceAll(label, "'", "''") + "'"
+}
+
+// ParsePerfdata parses the performance data in the output of a Nagios
+// plugin. Perfdata follows the first | on the first line of output, and the
+// first | in the rest of the output, which may span several lines. Entries
+// with an undetermined value (U) are skipped. The points are timestamped with
+// the current time.
+func ParsePerfdata(output string) (Points, error) {
+	lines := strings.Split(output, "\n")
+	var sections []string
+	if i := strings.Index(lines[0], "|"); i >= 0 {
+		sections = append(sections, lines[0][i+1:])
+	}
+	rest := strings.Join(lines[1:], "\n")
+	if i := strings.Index(rest, "|"); i >= 0 {
+		sections = append(sections, rest[i+1:])
+	}
+
+	timestamp := time.Now().Unix()
+	var points Points
+	for _, section := range sections {
+		for section = strings.TrimSpace(section); section != ""; section = strings.TrimSpace(section) {
+			var (
+				point *corev2.MetricPoint
+				err   error
+			)
+			point, section, err = parsePerfdataEntry(section)
+			if err != nil {
+				return nil, err
+			}
+			if point != nil {
+				point.Timestamp = timestamp
+				points = append(points, point)
+			}
+		}
+	}
+	return points, nil
+}
+
+// parsePerfdataEntry parses the entry at the start of s, returning the rest of
+// s. The point is nil if the entry's value is undetermined.
+func parsePerfdataEntry(s string) (*corev2.MetricPoint, string, error) {
+	var label string
+	if strings.HasPrefix(s, "'") {
+		var b strings.Builder
+		i := 1
+		for {
+			j := strings.IndexByte(s[i:], '\'')
+			if j < 0 {
+				return nil, "", fmt.Errorf("invalid perfdata %q: unterminated label", s)
+			}
+			b.WriteString(s[i : i+j])
+			i += j + 1
+			if !strings.HasPrefix(s[i:], "'") {
+				break
+			}
+			// a doubled quote is an escaped quote
+			b.WriteByte('\'')
+			i++
+		}
+		label, s = b.String(), s[i:]
+		if !strings.HasPrefix(s, "=") {
+			return nil, "", fmt.Errorf("invalid perfdata entry for %q: missing =", label)
+		}
+		s = s[1:]
+	} else {
+		i := strings.IndexAny(s, "= \t\r\n")
+		if i < 0 || s[i] != '=' {
+			return nil, "", fmt.Errorf("invalid perfdata %q: missing =", s)
+		}
+		label, s = s[:i], s[i+1:]
+	}
+	if label == "" {
+		return nil, "", errors.New("invalid perfdata: empty label")
+	}
+
+	data, rest := s, ""
+	if i := strings.IndexAny(s, " \t\r\n"); i >= 0 {
+		data, rest = s[:i], s[i:]
+	}
+	fields := strings.Split(data, ";")
+	value, uom := splitUOM(fields[0])
+	if value == "" && uom == "U" {
+		return nil, rest, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid perfdata value for %q: %q", label, fields[0])
+	}
+
+	point := &corev2.MetricPoint{Name: label, Value: f}
+	tagNames := []string{PerfdataUOMTag, PerfdataWarningTag, PerfdataCriticalTag, PerfdataMinTag, PerfdataMaxTag}
+	fields[0] = uom
+	for i, field := range fields {
+		if i < len(tagNames) && field != "" {
+			point.Tags = append(point.Tags, &corev2.MetricTag{Name: tagNames[i], Value: field})
+		}
+	}
+	return point, rest, nil
+}
+
+// splitUOM splits the unit of measurement off the end of a perfdata value.
+func splitUOM(s string) (string, string) {
+	i := len(s)
+	for i > 0 {
+		c := s[i-1]
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && c != '%' {
+			break
+		}
+		i--
+	}
+	return s[:i], s[i:]
+}
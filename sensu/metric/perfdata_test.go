@@ -0,0 +1,85 @@
+package metric
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type stringer string
+
+func (s stringer) String() string {
+	return string(s)
+}
+
+func TestToPerfdata(t *testing.T) {
+	points := Points{
+		{Name: "used", Value: 42.5, Tags: []*corev2.MetricTag{
+			{Name: PerfdataUOMTag, Value: "%"},
+			{Name: PerfdataMinTag, Value: "0"},
+			{Name: PerfdataMaxTag, Value: "100"},
+		}},
+		{Name: "free space", Value: 3},
+		{Name: "inodes", Value: 10, Tags: []*corev2.MetricTag{
+			{Name: PerfdataCriticalTag, Value: "@0:5"},
+			{Name: "mount", Value: "/"},
+		}},
+		nil,
+	}
+
+	assert.Equal(t, "used=42.5%;;;0;100 'free space'=3 inodes=10;;@0:5", points.ToPerfdata(nil, nil))
+	assert.Equal(t, "used=42.5%;80;90;0;100 'free space'=3;80;90 inodes=10;80;@0:5",
+		points.ToPerfdata(stringer("80"), stringer("90")))
+	assert.Equal(t, "", Points{}.ToPerfdata(nil, nil))
+}
+
+func TestParsePerfdata(t *testing.T) {
+	output := "DISK OK - free space: / 3326 MB (56%); | /=2643MB;5948;5958;0;5968 'it''s free'=1 load=U\n" +
+		"/ 15272 MB (77%);\n" +
+		"/boot 68 MB (69%); | /boot=68MB;88;93;0;98\n" +
+		"/home=69357MB;253404;253409;0;253414\n"
+
+	points, err := ParsePerfdata(output)
+	if !assert.NoError(t, err) || !assert.Len(t, points, 4) {
+		return
+	}
+
+	assert.Equal(t, "/", points[0].Name)
+	assert.Equal(t, float64(2643), points[0].Value)
+	assert.NotZero(t, points[0].Timestamp)
+	assert.Equal(t, []*corev2.MetricTag{
+		{Name: PerfdataUOMTag, Value: "MB"},
+		{Name: PerfdataWarningTag, Value: "5948"},
+		{Name: PerfdataCriticalTag, Value: "5958"},
+		{Name: PerfdataMinTag, Value: "0"},
+		{Name: PerfdataMaxTag, Value: "5968"},
+	}, points[0].Tags)
+
+	assert.Equal(t, "it's free", points[1].Name)
+	assert.Empty(t, points[1].Tags)
+	assert.Equal(t, "/boot", points[2].Name)
+	assert.Equal(t, "/home", points[3].Name)
+	assert.Equal(t, float64(69357), points[3].Value)
+
+	// rendering the parsed points gives back the perfdata
+	assert.Equal(t, "/=2643MB;5948;5958;0;5968 'it''s free'=1", points[:2].ToPerfdata(nil, nil))
+}
+
+func TestParsePerfdataNone(t *testing.T) {
+	points, err := ParsePerfdata("OK: all good\nno perfdata here")
+	assert.NoError(t, err)
+	assert.Empty(t, points)
+}
+
+func TestParsePerfdataInvalid(t *testing.T) {
+	for _, output := range []string{
+		"OK | novalue",
+		"OK | time=abc",
+		"OK | 'unterminated=1",
+		"OK | =1",
+	} {
+		_, err := ParsePerfdata(output)
+		assert.Error(t, err, output)
+	}
+}
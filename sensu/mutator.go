@@ -1,6 +1,7 @@
 package sensu
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -44,6 +45,8 @@ func NewMutator(config *PluginConfig, options []ConfigOption,
 		executeFunction:    executeFunction,
 	}
 	mutator.framework.SetWorkflow(mutator.workflow)
+	mutator.framework.SetValidation(mutator.validate)
+	mutator.framework.SetEventFunction(mutator.mutate)
 	if err := mutator.framework.Init(); err != nil {
 		log.Printf("failed to initialize mutator plugin: %s", err)
 	}
@@ -54,18 +57,37 @@ func NewMutator(config *PluginConfig, options []ConfigOption,
 // Deprecated: use NewGoMutator
 var NewGoMutator = NewMutator
 
-// Executes the handler's workflow
-func (m *Mutator) workflow(_ []string) (int, error) {
+// Validates the mutator's input
+func (m *Mutator) validate(_ []string) (int, error) {
+	return m.validateInput(m.framework.GetStdinEvent())
+}
+
+func (m *Mutator) validateInput(event *corev2.Event) (int, error) {
 	// Validate input using validateFunction
-	err := m.validationFunction(m.framework.GetStdinEvent())
+	err := m.validationFunction(event)
 	if err != nil {
-		return 1, ErrValidationFailed(err.Error())
+		return 1, validationFailed(err)
 	}
+	return 0, nil
+}
 
-	// Execute handler logic using executeFunction
-	event, err := m.executeFunction(m.framework.GetStdinEvent())
+// Executes the mutator's workflow
+func (m *Mutator) workflow(_ []string) (int, error) {
+	return m.mutate(m.framework.context(), m.framework.GetStdinEvent(), m.out)
+}
+
+// mutate validates and mutates a single event, writing the mutated event to
+// out. It is used for the event read from stdin, as well as for each event
+// received in serve mode.
+func (m *Mutator) mutate(_ context.Context, event *corev2.Event, out io.Writer) (int, error) {
+	if status, err := m.validateInput(event); err != nil {
+		return status, err
+	}
+
+	// Execute mutator logic using executeFunction
+	event, err := m.executeFunction(event)
 	if err != nil {
-		return 1, fmt.Errorf("error executing mutator: %s", err)
+		return 1, fmt.Errorf("error executing mutator: %w", err)
 	}
 
 	if event != nil {
@@ -74,9 +96,9 @@ func (m *Mutator) workflow(_ []string) (int, error) {
 			return 1, fmt.Errorf("error marshaling output event to json: %s", err)
 		}
 
-		_, _ = fmt.Fprintf(m.out, "%s", string(eventBytes))
+		_, _ = fmt.Fprintf(out, "%s", string(eventBytes))
 	} else {
-		_, _ = fmt.Fprint(m.out, "{}")
+		_, _ = fmt.Fprint(out, "{}")
 	}
 
 	return 0, err
@@ -86,3 +108,7 @@ func (m *Mutator) workflow(_ []string) (int, error) {
 func (m *Mutator) Execute() {
 	m.framework.Execute()
 }
+
+func (m *Mutator) getFramework() *pluginFramework {
+	return &m.framework
+}
@@ -0,0 +1,145 @@
+package sensu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu/metric"
+)
+
+// NagiosPluginResult is the outcome of running a Nagios plugin with
+// RunNagiosPlugin.
+type NagiosPluginResult struct {
+	// Stdout and Stderr are the plugin's output.
+	Stdout string
+	Stderr string
+
+	// ExitCode is the plugin's exit code.
+	ExitCode int
+
+	// Result is the plugin's output translated into a CheckResult, which
+	// can be enriched before it is returned by a check.
+	Result *CheckResult
+}
+
+// RunNagiosPlugin runs the Nagios plugin command with args, and translates
+// its output into a CheckResult. Exit codes 0 to 3 are the result's status,
+// and any other exit code is UNKNOWN. The first line of output is the
+// summary, and the rest of the output holds the details. Performance data is
+// parsed into metric points, or kept as it is if it can't be parsed. Any
+// output on stderr is added to the details.
+//
+// The plugin is killed when ctx is done; use Check.Context to run it with
+// the check's timeout. An error, marked with Unknown, is returned if the
+// plugin can't be run or is killed.
+func RunNagiosPlugin(ctx context.Context, command string, args ...string) (*NagiosPluginResult, error) {
+	// Output goes to files rather than pipes, so that a child process left
+	// behind by a killed plugin can't keep the plugin from being waited for.
+	stdout, err := ioutil.TempFile("", "nagios-stdout-")
+	if err != nil {
+		return nil, Unknown(fmt.Errorf("failed to run %s: %s", command, err))
+	}
+	defer removeFile(stdout)
+	stderr, err := ioutil.TempFile("", "nagios-stderr-")
+	if err != nil {
+		return nil, Unknown(fmt.Errorf("failed to run %s: %s", command, err))
+	}
+	defer removeFile(stderr)
+
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return nil, Unknown(fmt.Errorf("%s: %s", command, ctx.Err()))
+	}
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, Unknown(fmt.Errorf("failed to run %s: %s", command, err))
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	stdoutBytes, err := ioutil.ReadFile(stdout.Name())
+	if err != nil {
+		return nil, Unknown(fmt.Errorf("failed to read the output of %s: %s", command, err))
+	}
+	stderrBytes, err := ioutil.ReadFile(stderr.Name())
+	if err != nil {
+		return nil, Unknown(fmt.Errorf("failed to read the output of %s: %s", command, err))
+	}
+
+	result := &NagiosPluginResult{
+		Stdout:   string(stdoutBytes),
+		Stderr:   string(stderrBytes),
+		ExitCode: exitCode,
+	}
+	result.Result = translateNagiosOutput(result.Stdout, result.Stderr, exitCode)
+	return result, nil
+}
+
+// translateNagiosOutput translates the output of a Nagios plugin into a
+// CheckResult.
+func translateNagiosOutput(stdout, stderr string, exitCode int) *CheckResult {
+	result := &CheckResult{Status: exitCode}
+	if exitCode < CheckStateOK || exitCode > CheckStateUnknown {
+		result.Status = CheckStateUnknown
+	}
+
+	lines := strings.Split(strings.TrimRight(stdout, "\n"), "\n")
+	var perfdata []string
+	summary, firstPerfdata, _ := strings.Cut(lines[0], "|")
+	result.Summary = strings.TrimSpace(summary)
+	perfdata = append(perfdata, firstPerfdata)
+
+	// long output runs until the first |, and performance data follows it
+	inPerfdata := false
+	for _, line := range lines[1:] {
+		if inPerfdata {
+			perfdata = append(perfdata, line)
+			continue
+		}
+		text, rest, found := strings.Cut(line, "|")
+		if found {
+			inPerfdata = true
+			perfdata = append(perfdata, rest)
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+		}
+		result.Details = append(result.Details, strings.TrimRight(text, " \r"))
+	}
+
+	if points, err := metric.ParsePerfdata(stdout); err == nil {
+		result.Metrics = points
+	} else if raw := strings.Join(strings.Fields(strings.Join(perfdata, " ")), " "); raw != "" {
+		result.Perfdata = []string{raw}
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if result.Summary == "" {
+			result.Summary = line
+			continue
+		}
+		result.Details = append(result.Details, line)
+	}
+	if exitCode != result.Status {
+		result.Details = append(result.Details, fmt.Sprintf("exited with unexpected code %d", exitCode))
+	}
+	return result
+}
+
+func removeFile(f *os.File) {
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
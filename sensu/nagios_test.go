@@ -0,0 +1,85 @@
+package sensu
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateNagiosOutput(t *testing.T) {
+	stdout := "DISK WARNING - free space: / 3326 MB (56%); | /=2643MB;5948;5958;0;5968\n" +
+		"/ 15272 MB (77%);\n" +
+		"/boot 68 MB (69%); | /boot=68MB;88;93;0;98\n" +
+		"/home=69357MB;253404;253409;0;253414\n"
+
+	result := translateNagiosOutput(stdout, "", CheckStateWarning)
+	assert.Equal(t, CheckStateWarning, result.Status)
+	assert.Equal(t, "DISK WARNING - free space: / 3326 MB (56%);", result.Summary)
+	assert.Equal(t, []string{"/ 15272 MB (77%);", "/boot 68 MB (69%);"}, result.Details)
+	assert.Empty(t, result.Perfdata)
+	if assert.Len(t, result.Metrics, 3) {
+		assert.Equal(t, "/home", result.Metrics[2].Name)
+	}
+}
+
+func TestTranslateNagiosOutputUnusual(t *testing.T) {
+	result := translateNagiosOutput("", "cannot connect\nretrying failed\n", 127)
+	assert.Equal(t, CheckStateUnknown, result.Status)
+	assert.Equal(t, "cannot connect", result.Summary)
+	assert.Equal(t, []string{"retrying failed", "exited with unexpected code 127"}, result.Details)
+
+	result = translateNagiosOutput("OK | weird=", "", CheckStateOK)
+	assert.Empty(t, result.Metrics)
+	assert.Equal(t, []string{"weird="}, result.Perfdata)
+}
+
+func TestRunNagiosPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("needs a POSIX shell")
+	}
+
+	result, err := RunNagiosPlugin(context.Background(), sh, "-c", "echo 'LOAD CRITICAL | load1=9.5;4;8'; echo oops >&2; exit 2")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, result.ExitCode)
+		assert.Equal(t, "oops\n", result.Stderr)
+		assert.Equal(t, CheckStateCritical, result.Result.Status)
+		assert.Equal(t, "LOAD CRITICAL", result.Result.Summary)
+		assert.Equal(t, []string{"oops"}, result.Result.Details)
+		assert.Len(t, result.Result.Metrics, 1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = RunNagiosPlugin(ctx, sh, "-c", "sleep 5")
+	assert.Error(t, err)
+	status, _ := ErrorStatus(err)
+	assert.Equal(t, CheckStateUnknown, status)
+
+	_, err = RunNagiosPlugin(context.Background(), "/nonexistent/check_nothing")
+	assert.Error(t, err)
+}
+
+func TestCheckContext(t *testing.T) {
+	var deadline time.Time
+	var check *Check
+	noOp := func(*corev2.Event) (int, error) { return 0, nil }
+	check = NewCheck(&defaultCheckConfig, nil, noOp, func(*corev2.Event) (int, error) {
+		deadline, _ = check.Context().Deadline()
+		return 0, nil
+	}, false)
+	check.framework.cmd.SetArgs([]string{})
+	check.framework.exitFunction = func(int) {}
+	check.Execute()
+
+	assert.WithinDuration(t, time.Now().Add(time.Duration(defaultCheckConfig.Timeout)*time.Second), deadline, time.Second)
+	assert.Error(t, check.Context().Err())
+}
@@ -0,0 +1,96 @@
+package sensu
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// outputTruncatedMarker ends output that was truncated.
+const outputTruncatedMarker = "\n[output truncated]\n"
+
+// limitOutput redirects stdout, and the check's output, through a pipe that
+// truncates it to max bytes. The returned function restores stdout and writes
+// the output.
+func (c *Check) limitOutput(max int) (stop func(), err error) {
+	limiter := newTruncatingWriter(c.out, max)
+	restore, err := c.redirectOutput(limiter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to limit output: %s", err)
+	}
+	return func() {
+		restore()
+		_ = limiter.Close()
+	}, nil
+}
+
+// redirectOutput redirects stdout, and the check's output, through a pipe to
+// dst. The returned function restores them, once all of the output has been
+// copied to dst.
+func (c *Check) redirectOutput(dst io.Writer) (restore func(), err error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, out := os.Stdout, c.out
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = io.Copy(dst, r)
+	}()
+	os.Stdout, c.out = w, w
+
+	return func() {
+		os.Stdout, c.out = stdout, out
+		_ = w.Close()
+		<-done
+		_ = r.Close()
+	}, nil
+}
+
+// truncatingWriter holds up to limit bytes of output, which it writes when it
+// is closed. Longer output is truncated on a rune boundary, and ends with
+// outputTruncatedMarker, keeping the output within limit bytes.
+type truncatingWriter struct {
+	w     io.Writer
+	limit int
+	buf   bytes.Buffer
+	total int
+}
+
+func newTruncatingWriter(w io.Writer, limit int) *truncatingWriter {
+	return &truncatingWriter{w: w, limit: limit}
+}
+
+func (t *truncatingWriter) Write(p []byte) (int, error) {
+	t.total += len(p)
+	// anything past limit is dropped, but keep enough to find a rune boundary
+	if room := t.limit + utf8.UTFMax - t.buf.Len(); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		t.buf.Write(p[:room])
+	}
+	return len(p), nil
+}
+
+// Close writes the output, truncating it if it is longer than the limit.
+func (t *truncatingWriter) Close() error {
+	b := t.buf.Bytes()
+	if t.total <= t.limit {
+		_, err := t.w.Write(b)
+		return err
+	}
+	marker := outputTruncatedMarker
+	if len(marker) > t.limit {
+		marker = ""
+	}
+	cut := t.limit - len(marker)
+	for cut > 0 && !utf8.RuneStart(b[cut]) {
+		cut--
+	}
+	_, err := t.w.Write(append(b[:cut:cut], marker...))
+	return err
+}
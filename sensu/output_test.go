@@ -0,0 +1,63 @@
+package sensu
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncatingWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		limit    int
+		expected string
+	}{
+		{"under limit", []string{"hello ", "world"}, 11, "hello world"},
+		{"over limit", []string{strings.Repeat("a", 20), strings.Repeat("b", 20)}, 30, strings.Repeat("a", 10) + outputTruncatedMarker},
+		{"rune boundary", []string{"aaaaaaaaa", strings.Repeat("é", 20)}, 10 + len(outputTruncatedMarker), "aaaaaaaaa" + outputTruncatedMarker},
+		{"tiny limit", []string{"abcdef"}, 4, "abcd"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := newTruncatingWriter(&out, test.limit)
+			for _, s := range test.writes {
+				n, err := w.Write([]byte(s))
+				assert.NoError(t, err)
+				assert.Equal(t, len(s), n)
+			}
+			assert.NoError(t, w.Close())
+			assert.Equal(t, test.expected, out.String())
+			assert.LessOrEqual(t, out.Len(), test.limit)
+		})
+	}
+}
+
+func TestCheckMaxOutputSize(t *testing.T) {
+	config := defaultCheckConfig
+	config.MaxOutputSize = 64
+	noOp := func(*corev2.Event) (int, error) { return 0, nil }
+	check := NewCheckWithResult(&config, nil, noOp, func(*corev2.Event) (*CheckResult, error) {
+		fmt.Println("printed by the check")
+		return &CheckResult{Status: CheckStateCritical, Summary: "too much", Details: []string{strings.Repeat("x", 1000)}}, nil
+	}, false)
+	check.framework.cmd.SetArgs([]string{})
+
+	var out bytes.Buffer
+	var exitStatus = -99
+	check.out = &out
+	check.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	check.Execute()
+
+	assert.Equal(t, CheckStateCritical, exitStatus)
+	assert.Equal(t, 64, out.Len())
+	assert.True(t, strings.HasPrefix(out.String(), "printed by the check\nCRITICAL: too much\nxxx"), out.String())
+	assert.True(t, strings.HasSuffix(out.String(), outputTruncatedMarker))
+}
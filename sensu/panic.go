@@ -0,0 +1,42 @@
+package sensu
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// panicError is returned in place of a panic in one of the plugin's functions.
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (e panicError) Error() string {
+	if len(e.stack) == 0 {
+		return fmt.Sprintf("plugin panicked: %v (run with --debug for a stack trace)", e.value)
+	}
+	return fmt.Sprintf("plugin panicked: %v\n%s", e.value, e.stack)
+}
+
+// safeCall calls f, recovering from any panic in it. A panic results in the
+// plugin's error exit status, and an error that describes the panic. The
+// error includes a stack trace when the plugin is run with --debug.
+// Otherwise, the exit status is mapped from the error returned by f, if any.
+func (p *pluginFramework) safeCall(f func() (int, error)) (status int, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			perr := panicError{value: value}
+			if p.debugEnabled() {
+				perr.stack = debug.Stack()
+			}
+			status, err = p.errorExitStatus, perr
+		}
+	}()
+	return p.errorExit(f())
+}
+
+// debugEnabled tells if the plugin is run with --debug. If the plugin defines
+// its own --debug flag, it is honoured.
+func (p *pluginFramework) debugEnabled() bool {
+	return p.debug || p.flagValue("debug") == "true"
+}
@@ -0,0 +1,96 @@
+package sensu
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func panicCheckExecuteUtil(t *testing.T, args []string) (int, string) {
+	t.Helper()
+	noOp := func(*corev2.Event) (int, error) { return 0, nil }
+	check := NewGoCheck(&defaultCheckConfig, nil, noOp, func(event *corev2.Event) (int, error) {
+		var entity *corev2.Entity
+		return 0, fmt.Errorf("%s", entity.Name)
+	}, false)
+	check.framework.cmd.SetArgs(args)
+
+	var exitStatus = -99
+	var errorStr string
+	check.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	check.framework.errorLogFunction = func(format string, a ...interface{}) {
+		errorStr = fmt.Sprintf(format, a...)
+	}
+	check.Execute()
+
+	return exitStatus, errorStr
+}
+
+func TestCheckPanic(t *testing.T) {
+	status, errorStr := panicCheckExecuteUtil(t, nil)
+	assert.Equal(t, CheckStateUnknown, status)
+	assert.Contains(t, errorStr, "plugin panicked: runtime error: invalid memory address or nil pointer dereference")
+	assert.NotContains(t, errorStr, "goroutine")
+}
+
+func TestCheckPanicDebug(t *testing.T) {
+	status, errorStr := panicCheckExecuteUtil(t, []string{"--debug"})
+	assert.Equal(t, CheckStateUnknown, status)
+	assert.Contains(t, errorStr, "plugin panicked")
+	assert.Contains(t, errorStr, "goroutine")
+}
+
+func TestHandlerPanic(t *testing.T) {
+	clearEnvironment()
+	status, errorStr := goHandlerExecuteUtil(t, &defaultHandlerConfig, "test/event-no-override.json", nil,
+		func(*corev2.Event) error {
+			panic("validation is broken")
+		}, func(*corev2.Event) error {
+			return nil
+		}, "", 0, false)
+	assert.Equal(t, 1, status)
+	assert.Contains(t, errorStr, "plugin panicked: validation is broken")
+}
+
+func TestBatchPanic(t *testing.T) {
+	clearEnvironment()
+	event := compactEventFile(t, "test/event-no-override.json")
+	input := strings.Join([]string{event, event, event}, "\n")
+
+	var count int
+	status, errorStr := batchExecuteUtil(t, strings.NewReader(input), nil, nil,
+		func(event *corev2.Event) error {
+			count++
+			if count == 2 {
+				panic("second event")
+			}
+			return nil
+		})
+
+	assert.Equal(t, 1, status)
+	assert.Contains(t, errorStr, "1 of 3 events failed")
+	assert.Equal(t, 3, count)
+}
+
+func TestServePanic(t *testing.T) {
+	clearEnvironment()
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, nil, noOp, func(*corev2.Event) error {
+		panic("handler is broken")
+	})
+
+	url, stop := startServe(t, &handler.framework, time.Second)
+	code, body := postEventFile(t, url, "test/event-no-override.json")
+	assert.Equal(t, 500, code)
+	assert.Contains(t, body, "plugin panicked: handler is broken")
+	// the server survives the panic
+	code, _ = postEventFile(t, url, "test/event-no-override.json")
+	assert.Equal(t, 500, code)
+	assert.NoError(t, stop())
+}
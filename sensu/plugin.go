@@ -1,6 +1,7 @@
 package sensu
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,9 +12,11 @@ import (
 	"path"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/httpclient"
 	"github.com/sensu/sensu-plugin-sdk/version"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -102,6 +105,9 @@ type SlicePluginConfigOption[T SliceOptionValue] struct {
 	// the SlicePluginConfigOption is instantiated with a non-string type, then
 	// this option will have no effect.
 	UseCobraStringArray bool
+	// Filename indicates that the option is a path to a file. Shell completion
+	// suggests files for the option instead of the values in Allow.
+	Filename bool
 }
 
 // MapPluginConfigOption is like PluginConfigOption, but permits using maps.
@@ -190,6 +196,9 @@ type PluginConfigOption[T OptionValue] struct {
 	// Restrict are ignored. If Allow is not set, or is set to an empty slice,
 	// then Restrict is consulted.
 	Allow []T
+	// Filename indicates that the option is a path to a file. Shell completion
+	// suggests files for the option instead of the values in Allow.
+	Filename bool
 }
 
 // PluginConfig defines the base plugin configuration.
@@ -198,49 +207,107 @@ type PluginConfig struct {
 	Short    string
 	Timeout  uint64
 	Keyspace string
+
+	// MaxOutputSize is the maximum size of a check's output on stdout, in
+	// bytes. Longer output is truncated. Zero means no limit.
+	MaxOutputSize int
 }
 
 // pluginFramework defines the basic configuration to be used by all plugin types.
 type pluginFramework struct {
 	config                 *PluginConfig
 	options                []ConfigOption
+	sharedOptions          []ConfigOption
 	sensuEvent             *corev2.Event
 	eventReader            io.Reader
 	pluginWorkflowFunction func([]string) (int, error)
+	pluginValidateFunction func([]string) (int, error)
+	pluginEventFunction    func(context.Context, *corev2.Event, io.Writer) (int, error)
 	cmd                    *cobra.Command
 	readEvent              bool
 	eventMandatory         bool
 	eventValidation        bool
 	configurationOverrides bool
 	verbose                bool
+	validateOnly           bool
+	eventFile              string
+	eventFromAPI           string
+	apiURL                 string
+	apiKey                 string
+	batch                  bool
+	batchConcurrency       int
+	serveConcurrency       int
+	commandLineOptions     []func()
+	serveCmd               *cobra.Command
 	exitStatus             int
 	errorExitStatus        int
+	debug                  bool
+	ctx                    context.Context
 	exitFunction           func(int)
 	errorLogFunction       func(format string, a ...interface{})
 }
 
+// allOptions returns the plugin's options, followed by any options it shares
+// with other plugins in a Suite.
+func (p *pluginFramework) allOptions() []ConfigOption {
+	if len(p.sharedOptions) == 0 {
+		return p.options
+	}
+	options := make([]ConfigOption, 0, len(p.options)+len(p.sharedOptions))
+	options = append(options, p.options...)
+	return append(options, p.sharedOptions...)
+}
+
 func (p *pluginFramework) SetWorkflow(f func([]string) (int, error)) {
 	p.pluginWorkflowFunction = f
 }
 
+// SetValidation sets the function that runs the plugin's own validation. It is
+// used in place of the workflow function when the plugin is run with
+// --validate-only.
+func (p *pluginFramework) SetValidation(f func([]string) (int, error)) {
+	p.pluginValidateFunction = f
+}
+
 func (p *pluginFramework) readSensuEvent() error {
-	eventJSON, err := ioutil.ReadAll(p.eventReader)
-	if err != nil {
-		if p.eventMandatory {
-			return fmt.Errorf("failed to read stdin: %s", err)
-		} else {
-			// if event is not mandatory return without going any further
-			return nil
+	var sensuEvent *corev2.Event
+	if p.eventFromAPI != "" {
+		if p.eventFile != "" {
+			return errors.New("--event-file and --event-from-api can't be used together")
+		}
+		event, err := p.getAPIEvent()
+		if err != nil {
+			return fmt.Errorf("failed to get event from the Sensu API: %s", err)
+		}
+		sensuEvent = event
+	} else {
+		reader, source := p.eventReader, "stdin"
+		if p.eventFile != "" {
+			f, err := os.Open(p.eventFile)
+			if err != nil {
+				return fmt.Errorf("failed to open event file: %s", err)
+			}
+			defer f.Close()
+			reader, source = f, p.eventFile
+		}
+		eventJSON, err := ioutil.ReadAll(reader)
+		if err != nil {
+			if p.eventMandatory || p.eventFile != "" {
+				return fmt.Errorf("failed to read %s: %s", source, err)
+			} else {
+				// if event is not mandatory return without going any further
+				return nil
+			}
 		}
-	}
 
-	sensuEvent := &corev2.Event{}
-	err = json.Unmarshal(eventJSON, sensuEvent)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal stdin event: %s", err)
+		sensuEvent = &corev2.Event{}
+		err = json.Unmarshal(eventJSON, sensuEvent)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal %s event: %s", source, err)
+		}
 	}
 	if p.eventValidation {
-		if err = validateEvent(sensuEvent); err != nil {
+		if err := validateEvent(sensuEvent); err != nil {
 			return err
 		}
 	}
@@ -249,6 +316,51 @@ func (p *pluginFramework) readSensuEvent() error {
 	return nil
 }
 
+// getAPIEvent gets the event named by --event-from-api from the Sensu API.
+// The connection settings are read from flags by name, so that plugins that
+// define their own --sensu-api-url or SensuSecurityOptions flags have them
+// honoured.
+func (p *pluginFramework) getAPIEvent() (*corev2.Event, error) {
+	parts := strings.Split(p.eventFromAPI, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("%q is not of the form namespace/entity/check", p.eventFromAPI)
+	}
+	config := httpclient.CoreClientConfig{
+		URL:                strings.TrimSuffix(p.flagValue("sensu-api-url"), "/"),
+		APIKey:             p.flagValue("sensu-api-key"),
+		InsecureSkipVerify: p.flagValue("sensu-insecure-skip-verify") == "true",
+	}
+	if caCert := p.flagValue("sensu-ca-cert"); caCert != "" {
+		security := SecurityConfig{CACertificate: caCert}
+		cert, err := security.GetCACertificate()
+		if err != nil {
+			return nil, err
+		}
+		config.CACert = cert
+	}
+	timeout := 10 * time.Second
+	if p.config.Timeout > 0 {
+		timeout = time.Duration(p.config.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client := httpclient.NewCoreClient(config)
+	event := new(corev2.Event)
+	request := httpclient.NewEventRequest(parts[0], parts[1], parts[2])
+	if _, err := client.GetResource(ctx, request, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// SetEventFunction sets the function that validates and executes the plugin
+// for a single event, writing any output to the supplied writer. It is used
+// when the plugin processes more than one event in a single run.
+func (p *pluginFramework) SetEventFunction(f func(context.Context, *corev2.Event, io.Writer) (int, error)) {
+	p.pluginEventFunction = f
+}
+
 // Init sets up the framework's configuration parsing and execution environment.
 func (p *pluginFramework) Init() error {
 	if p.pluginWorkflowFunction == nil {
@@ -264,7 +376,8 @@ func (p *pluginFramework) Init() error {
 			return err
 		}
 		err := p.cobraExecuteFunction(args)
-		if _, ok := err.(ErrValidationFailed); !ok {
+		var verr ErrValidationFailed
+		if !errors.As(err, &verr) {
 			p.cmd.SilenceUsage = true
 		} else {
 			err = fmt.Errorf("error validating input: %s", err)
@@ -284,8 +397,66 @@ func (p *pluginFramework) Init() error {
 			fmt.Println(version.Version())
 		},
 	})
+	setupCompletion(p.cmd)
+
+	if err := p.setupFlags(p.cmd); err != nil {
+		return err
+	}
+	// --debug is set up before the serve command, so that serve shares it
+	if p.cmd.Flags().Lookup("debug") == nil {
+		p.cmd.Flags().BoolVar(&p.debug, "debug", false, "Print a stack trace if the plugin panics")
+	}
+	if p.pluginEventFunction != nil {
+		p.setupServeCommand()
+	}
+	p.setupFrameworkFlags(p.cmd)
+	return nil
+}
+
+// setupFrameworkFlags adds the flags that every plugin supports. A framework
+// flag is skipped if the plugin already defines a flag of the same name.
+func (p *pluginFramework) setupFrameworkFlags(cmd *cobra.Command) {
+	if p.pluginValidateFunction != nil && cmd.Flags().Lookup("validate-only") == nil {
+		cmd.Flags().BoolVar(&p.validateOnly, "validate-only", false, "Validate the configuration and event, then exit without executing")
+	}
+	if p.readEvent {
+		p.setupEventSourceFlags(cmd)
+	}
+}
+
+// flagValue returns the value of the named flag, or the empty string if the
+// plugin has no such flag.
+func (p *pluginFramework) flagValue(name string) string {
+	flag := p.cmd.Flags().Lookup(name)
+	if flag == nil {
+		return ""
+	}
+	return flag.Value.String()
+}
 
-	return p.setupFlags(p.cmd)
+// setupEventSourceFlags adds the flags that let the event be read from
+// somewhere other than stdin.
+func (p *pluginFramework) setupEventSourceFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	if flags.Lookup("event-file") == nil {
+		flags.StringVar(&p.eventFile, "event-file", "", "Read the event from a file instead of stdin")
+		_ = cmd.MarkFlagFilename("event-file")
+	}
+	if flags.Lookup("event-from-api") == nil {
+		flags.StringVar(&p.eventFromAPI, "event-from-api", "", "Read the event from the Sensu API instead of stdin, given as namespace/entity/check")
+	}
+	if flags.Lookup("sensu-api-url") == nil {
+		url := os.Getenv("SENSU_API_URL")
+		if url == "" {
+			url = "http://127.0.0.1:8080"
+		}
+		flags.StringVar(&p.apiURL, "sensu-api-url", url, "The Sensu API URL used by --event-from-api")
+	}
+	if flags.Lookup("sensu-api-key") == nil {
+		flags.StringVar(&p.apiKey, "sensu-api-key", os.Getenv("SENSU_API_KEY"), "The Sensu API key used by --event-from-api")
+		// the key must not be printed in the usage message
+		flags.Lookup("sensu-api-key").DefValue = ""
+	}
 }
 
 func (p *pluginFramework) setupFlags(cmd *cobra.Command) error {
@@ -369,7 +540,11 @@ func (p *PluginConfigOption[T]) SetupFlag(cmd *cobra.Command) error {
 	if p.Secret {
 		flag.DefValue = ""
 	}
-	return nil
+	var allowed []string
+	if len(p.Allow) > 0 {
+		allowed = completionValues(append([]T{p.Default}, p.Allow...)...)
+	}
+	return registerFlagCompletion(cmd, p.Argument, p.Filename, allowed)
 }
 
 // integer overflow isn't real, it can't hurt you
@@ -425,7 +600,11 @@ func (p *SlicePluginConfigOption[T]) SetupFlag(cmd *cobra.Command) error {
 	if p.Secret {
 		flag.DefValue = ""
 	}
-	return nil
+	var allowed []string
+	if len(p.Allow) > 0 {
+		allowed = completionValues(append(append([]T{}, p.Default...), p.Allow...)...)
+	}
+	return registerFlagCompletion(cmd, p.Argument, p.Filename, allowed)
 }
 
 func castMap[T int | int64](m map[string]interface{}) map[string]T {
@@ -469,7 +648,11 @@ func (p *MapPluginConfigOption[T]) SetupFlag(cmd *cobra.Command) error {
 	if p.Secret {
 		flag.DefValue = ""
 	}
-	return nil
+	var allowed []string
+	if len(p.Allow) > 0 {
+		allowed = mapCompletionValues(p.Default, p.Allow)
+	}
+	return registerFlagCompletion(cmd, p.Argument, false, allowed)
 }
 
 // GetStdinEvent gets the event that was received on stdin, if any. Can return
@@ -482,9 +665,33 @@ type allowRestrictValidator interface {
 	validateAllowRestrict() error
 }
 
+// validateAllowRestrict checks the values of all the options against their
+// Allow and Restrict lists.
+func (p *pluginFramework) validateAllowRestrict() error {
+	for _, option := range p.allOptions() {
+		if v, ok := option.(allowRestrictValidator); ok {
+			if err := v.validateAllowRestrict(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // cobraExecuteFunction is called by the argument's execute. The configuration overrides will be processed if necessary
 // and the pluginWorkflowFunction function executed
 func (p *pluginFramework) cobraExecuteFunction(args []string) error {
+	ctx, cancel := p.timeoutContext()
+	defer cancel()
+	p.ctx = ctx
+
+	if p.validateOnly {
+		return p.validateOnlyFunction(args)
+	}
+	if p.batch {
+		return p.batchFunction()
+	}
+
 	// Read the Sensu event if required
 	if p.readEvent {
 		err := p.readSensuEvent()
@@ -494,30 +701,49 @@ func (p *pluginFramework) cobraExecuteFunction(args []string) error {
 		}
 	}
 
+	// Keep the options' command line and environment values, so that they
+	// can be restored after the event's configuration overrides
+	p.commandLineOptions = p.snapshotOptions()
+
 	// If there is an event process configuration overrides if necessary
 	if p.sensuEvent != nil && p.configurationOverrides {
-		err := configurationOverrides(p.config, p.options, p.sensuEvent, p.verbose)
+		err := configurationOverrides(p.config, p.allOptions(), p.sensuEvent, p.verbose)
 		if err != nil {
 			p.exitStatus = p.errorExitStatus
 			return err
 		}
 	}
 
-	for _, option := range p.options {
-		if v, ok := option.(allowRestrictValidator); ok {
-			if err := v.validateAllowRestrict(); err != nil {
-				p.exitStatus = p.errorExitStatus
-				return err
-			}
-		}
+	if err := p.validateAllowRestrict(); err != nil {
+		p.exitStatus = p.errorExitStatus
+		return err
 	}
 
-	exitStatus, err := p.pluginWorkflowFunction(args)
+	exitStatus, err := p.safeCall(func() (int, error) {
+		return p.pluginWorkflowFunction(args)
+	})
 	p.exitStatus = exitStatus
 
 	return err
 }
 
+// timeoutContext returns a context that is done when the plugin's timeout
+// expires. It is never done if the plugin has no timeout.
+func (p *pluginFramework) timeoutContext() (context.Context, context.CancelFunc) {
+	if p.config.Timeout == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), time.Duration(p.config.Timeout)*time.Second)
+}
+
+// context returns the context of the plugin's current execution.
+func (p *pluginFramework) context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
 // Execute executes the plugin. Check, Handler, and Mutator all call this in
 // their own Execute functions.
 func (p *pluginFramework) Execute() {
@@ -602,6 +828,61 @@ func (p *MapPluginConfigOption[T]) SetValue(valueStr string) (err error) {
 	return json.Unmarshal([]byte(valueStr), p.Value)
 }
 
+// optionSnapshotter is implemented by options whose current value can be
+// saved and later restored, so that per-event overrides can be undone.
+type optionSnapshotter interface {
+	snapshot() (restore func())
+}
+
+// Snapshotter can be implemented by a ConfigOption defined outside of this
+// package. Snapshot saves the option's current value, and the returned
+// function restores it, so that annotation overrides applied to one event
+// in batch and serve modes don't carry over to the next.
+type Snapshotter interface {
+	Snapshot() (restore func())
+}
+
+func (p *PluginConfigOption[T]) snapshot() func() {
+	if p.Value == nil {
+		return func() {}
+	}
+	value := *p.Value
+	return func() {
+		*p.Value = value
+	}
+}
+
+func (p *SlicePluginConfigOption[T]) snapshot() func() {
+	if p.Value == nil {
+		return func() {}
+	}
+	value := append([]T(nil), (*p.Value)...)
+	return func() {
+		*p.Value = append([]T(nil), value...)
+	}
+}
+
+func (p *MapPluginConfigOption[T]) snapshot() func() {
+	if p.Value == nil {
+		return func() {}
+	}
+	value := copyMap(*p.Value)
+	return func() {
+		*p.Value = copyMap(value)
+	}
+}
+
+func copyMap[T MapOptionValue](m map[string]T) map[string]T {
+	if m == nil {
+		return nil
+	}
+	result := make(map[string]T, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
+
 func (p *MapPluginConfigOption[T]) validateAllowRestrict() error {
 	if len(p.Allow) > 0 {
 		for k, v := range *p.Value {
@@ -752,22 +1033,29 @@ func configurationOverrides(config *PluginConfig, options []ConfigOption, event
 		return nil
 	}
 	for _, opt := range options {
-		result, err := opt.SetAnnotationValue(config.Keyspace, event)
-		if err != nil {
+		if err := configurationOverride(config, opt, event, verbose); err != nil {
 			return err
 		}
-		if verbose {
-			var what string
-			if result.CheckAnnotation {
-				what = "check"
-			} else if result.EntityAnnotation {
-				what = "entity"
-			} else {
-				continue
-			}
-			msg := "overriding default plugin configuration with value of \"%s.annotations.%s\" (%q)"
-			log.Printf(msg, what, result.AnnotationKey, result.AnnotationValue)
+	}
+	return nil
+}
+
+func configurationOverride(config *PluginConfig, opt ConfigOption, event *corev2.Event, verbose bool) error {
+	result, err := opt.SetAnnotationValue(config.Keyspace, event)
+	if err != nil {
+		return err
+	}
+	if verbose {
+		var what string
+		if result.CheckAnnotation {
+			what = "check"
+		} else if result.EntityAnnotation {
+			what = "entity"
+		} else {
+			return nil
 		}
+		msg := "overriding default plugin configuration with value of \"%s.annotations.%s\" (%q)"
+		log.Printf(msg, what, result.AnnotationKey, result.AnnotationValue)
 	}
 	return nil
 }
@@ -1,10 +1,15 @@
 package sensu
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
+	corev2 "github.com/sensu/core/v2"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -399,3 +404,83 @@ func TestSetMapOptionValueAllowAndRestrict(t *testing.T) {
 		t.Error("expected non-nil error")
 	}
 }
+
+func eventSourceExecuteUtil(t *testing.T, args []string) (int, string, *corev2.Event) {
+	t.Helper()
+
+	var event *corev2.Event
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, nil, noOp, func(e *corev2.Event) error {
+		event = e
+		return nil
+	})
+	handler.framework.cmd.SetArgs(args)
+
+	var exitStatus = -99
+	var errorStr string
+	// stdin must not be consulted when another event source is given
+	handler.framework.eventReader = getFileReader("test/event-invalid-json.json")
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.framework.errorLogFunction = func(format string, a ...interface{}) {
+		errorStr = fmt.Sprintf(format, a...)
+	}
+	handler.Execute()
+
+	return exitStatus, errorStr, event
+}
+
+func TestReadEventFromFile(t *testing.T) {
+	status, errorStr, event := eventSourceExecuteUtil(t, []string{"--event-file", "test/event-check-override.json"})
+	assert.Equal(t, 0, status)
+	assert.Empty(t, errorStr)
+	if assert.NotNil(t, event) {
+		assert.Equal(t, "value-check1", event.Check.Annotations["sensu.io/plugins/segp/config/path1"])
+	}
+
+	status, errorStr, _ = eventSourceExecuteUtil(t, []string{"--event-file", "test/does-not-exist.json"})
+	assert.Equal(t, 1, status)
+	assert.Contains(t, errorStr, "failed to open event file")
+}
+
+func TestReadEventFromAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/core/v2/namespaces/default/events/server/network" {
+			http.NotFound(w, req)
+			return
+		}
+		if req.Header.Get("Authorization") != "Key secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(corev2.FixtureEvent("server", "network"))
+	}))
+	defer server.Close()
+
+	status, errorStr, event := eventSourceExecuteUtil(t, []string{
+		"--event-from-api", "default/server/network",
+		"--sensu-api-url", server.URL,
+		"--sensu-api-key", "secret",
+	})
+	assert.Equal(t, 0, status)
+	assert.Empty(t, errorStr)
+	if assert.NotNil(t, event) {
+		assert.Equal(t, "server/network", EventKey(event))
+	}
+
+	status, errorStr, _ = eventSourceExecuteUtil(t, []string{
+		"--event-from-api", "default/server",
+		"--sensu-api-url", server.URL,
+	})
+	assert.Equal(t, 1, status)
+	assert.Contains(t, errorStr, "not of the form namespace/entity/check")
+
+	status, errorStr, _ = eventSourceExecuteUtil(t, []string{
+		"--event-from-api", "default/server/network",
+		"--sensu-api-url", server.URL,
+		"--sensu-api-key", "wrong",
+	})
+	assert.Equal(t, 1, status)
+	assert.Contains(t, errorStr, "error 401")
+}
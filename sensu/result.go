@@ -0,0 +1,95 @@
+package sensu
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu/metric"
+)
+
+// CheckResult is the result of a check created with NewCheckWithResult. The
+// framework renders it to stdout, and exits with its status.
+type CheckResult struct {
+	// Status is the check's exit status, usually one of the CheckState
+	// constants.
+	Status int
+
+	// Summary is a one line description of the result.
+	Summary string
+
+	// Details are extra lines of output, printed after the summary.
+	Details []string
+
+	// Metrics are rendered as perfdata, after any entries in Perfdata. See
+	// metric.Points.ToPerfdata.
+	Metrics []*corev2.MetricPoint
+
+	// Perfdata entries are rendered as they are, in Nagios perfdata format,
+	// for example "used=42%;80;90".
+	Perfdata []string
+}
+
+// StatusName returns the name of a check status, such as "OK" or "CRITICAL".
+func StatusName(status int) string {
+	switch status {
+	case CheckStateOK:
+		return "OK"
+	case CheckStateWarning:
+		return "WARNING"
+	case CheckStateCritical:
+		return "CRITICAL"
+	case CheckStateUnknown:
+		return "UNKNOWN"
+	default:
+		return fmt.Sprintf("STATUS %d", status)
+	}
+}
+
+// Render writes the result in Nagios plugin format. The first line holds the
+// status, the summary and the perfdata, and the details follow on their own
+// lines:
+//
+//	CRITICAL: 2 of 3 disks are full | /=98%;80;90 /home=95%;80;90
+//	/ is 98% full
+//	/home is 95% full
+func (r *CheckResult) Render(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(StatusName(r.Status))
+	if summary := strings.TrimSpace(r.Summary); summary != "" {
+		b.WriteString(": ")
+		b.WriteString(singleLine(summary))
+	}
+	perfdata := r.perfdata()
+	if len(perfdata) > 0 {
+		b.WriteString(" | ")
+		b.WriteString(strings.Join(perfdata, " "))
+	}
+	b.WriteString("\n")
+	for _, line := range r.Details {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (r *CheckResult) perfdata() []string {
+	perfdata := make([]string, 0, len(r.Perfdata)+len(r.Metrics))
+	for _, entry := range r.Perfdata {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			perfdata = append(perfdata, entry)
+		}
+	}
+	if metrics := metric.Points(r.Metrics).ToPerfdata(nil, nil); metrics != "" {
+		perfdata = append(perfdata, metrics)
+	}
+	return perfdata
+}
+
+// singleLine puts s on a single line, so that it can't spill over into the
+// details. Pipes are replaced too, as they would start the perfdata.
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(s, "|", "/")), " ")
+}
@@ -0,0 +1,97 @@
+package sensu
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckResultRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   CheckResult
+		expected string
+	}{
+		{
+			name:     "status only",
+			result:   CheckResult{Status: CheckStateOK},
+			expected: "OK\n",
+		},
+		{
+			name: "everything",
+			result: CheckResult{
+				Status:   CheckStateCritical,
+				Summary:  "2 of 3 disks are full",
+				Details:  []string{"/ is 98% full", "/home is 95% full"},
+				Perfdata: []string{"/=98%;80;90", "/home=95%;80;90"},
+				Metrics: []*corev2.MetricPoint{
+					{Name: "disks", Value: 3},
+					{Name: "free space", Value: 1.5},
+				},
+			},
+			expected: "CRITICAL: 2 of 3 disks are full | /=98%;80;90 /home=95%;80;90 disks=3 'free space'=1.5\n" +
+				"/ is 98% full\n/home is 95% full\n",
+		},
+		{
+			name:     "multi-line summary",
+			result:   CheckResult{Status: 7, Summary: "first\nsecond | third"},
+			expected: "STATUS 7: first second / third\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.NoError(t, test.result.Render(&out))
+			assert.Equal(t, test.expected, out.String())
+		})
+	}
+}
+
+func resultCheckExecuteUtil(t *testing.T, executeFunction func(*corev2.Event) (*CheckResult, error)) (int, string, string) {
+	t.Helper()
+	noOp := func(*corev2.Event) (int, error) { return 0, nil }
+	check := NewCheckWithResult(&defaultCheckConfig, nil, noOp, executeFunction, false)
+	check.framework.cmd.SetArgs([]string{})
+
+	var out bytes.Buffer
+	var errorStr string
+	var exitStatus = -99
+	check.out = &out
+	check.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	check.framework.errorLogFunction = func(format string, a ...interface{}) {
+		errorStr = fmt.Sprintf(format, a...)
+	}
+	check.Execute()
+
+	return exitStatus, out.String(), errorStr
+}
+
+func TestNewCheckWithResult(t *testing.T) {
+	status, out, errorStr := resultCheckExecuteUtil(t, func(*corev2.Event) (*CheckResult, error) {
+		return &CheckResult{Status: CheckStateWarning, Summary: "load is high", Perfdata: []string{"load1=4.2"}}, nil
+	})
+	assert.Equal(t, CheckStateWarning, status)
+	assert.Equal(t, "WARNING: load is high | load1=4.2\n", out)
+	assert.Empty(t, errorStr)
+}
+
+func TestNewCheckWithResultError(t *testing.T) {
+	status, out, errorStr := resultCheckExecuteUtil(t, func(*corev2.Event) (*CheckResult, error) {
+		return nil, errors.New("connection refused")
+	})
+	assert.Equal(t, CheckStateUnknown, status)
+	assert.Empty(t, out)
+	assert.Contains(t, errorStr, "error executing check: connection refused")
+
+	status, out, _ = resultCheckExecuteUtil(t, func(*corev2.Event) (*CheckResult, error) {
+		return &CheckResult{Status: CheckStateCritical, Summary: "partial"}, Critical(errors.New("timed out"))
+	})
+	assert.Equal(t, CheckStateCritical, status)
+	assert.Equal(t, "CRITICAL: partial\n", out)
+}
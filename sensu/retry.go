@@ -0,0 +1,119 @@
+package sensu
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// Retry policy defaults.
+const (
+	DefaultRetryBackoff    = time.Second
+	DefaultRetryMaxBackoff = 30 * time.Second
+	DefaultRetryMultiplier = 2
+)
+
+// RetryPolicy controls how a Handler retries its execute function when it
+// fails with an error marked with Transient. Other errors are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the execute function is
+	// called for an event, including the first. Zero or one disables
+	// retries.
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry. It defaults to
+	// DefaultRetryBackoff.
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between attempts. It defaults to
+	// DefaultRetryMaxBackoff.
+	MaxBackoff time.Duration
+
+	// Multiplier grows the delay after each retry. It defaults to
+	// DefaultRetryMultiplier.
+	Multiplier float64
+
+	// Jitter randomizes each delay by up to this fraction of it, so that
+	// handlers that failed together don't retry together. It is between 0
+	// and 1; larger values are treated as 1.
+	Jitter float64
+}
+
+// SetRetryPolicy sets the policy for retrying the handler's execute function.
+// Retries stop at the handler's timeout, set in its PluginConfig, which is
+// counted for each event from its first attempt: the handler doesn't wait for
+// a retry that would start after the timeout expires.
+func (h *Handler) SetRetryPolicy(policy RetryPolicy) {
+	h.retryPolicy = policy
+}
+
+// execute calls the handler's execute function, retrying it according to the
+// handler's retry policy. Retries stop when ctx is done, or when the handler's
+// timeout expires, whichever comes first.
+func (h *Handler) execute(ctx context.Context, event *corev2.Event) error {
+	if timeout := h.framework.config.Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+	return h.retryPolicy.do(ctx, EventKey(event), func() error {
+		return h.executeFunction(event)
+	})
+}
+
+// do calls f until it succeeds, fails with an error that isn't transient, or
+// runs out of attempts or time. It returns f's last error.
+func (r RetryPolicy) do(ctx context.Context, key string, f func() error) error {
+	backoff := r.Backoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	maxBackoff := r.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultRetryMaxBackoff
+	}
+	multiplier := r.Multiplier
+	if multiplier <= 0 {
+		multiplier = DefaultRetryMultiplier
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || !IsTransient(err) || attempt >= r.MaxAttempts {
+			return err
+		}
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		delay := jitter(backoff, r.Jitter)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			log.Printf("attempt %d of %d for event %s failed: %s; not retrying, the timeout would expire first",
+				attempt, r.MaxAttempts, key, err)
+			return err
+		}
+		log.Printf("attempt %d of %d for event %s failed: %s; retrying in %s", attempt, r.MaxAttempts, key, err, delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff = time.Duration(float64(backoff) * multiplier)
+	}
+}
+
+// jitter randomizes delay by up to fraction of it, either way. fraction is
+// clamped to [0, 1], so that the delay is never negative.
+func jitter(delay time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return delay
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	return delay + time.Duration((rand.Float64()*2-1)*fraction*float64(delay))
+}
@@ -0,0 +1,141 @@
+package sensu
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, Jitter: 0.5}
+	failing := func(err error, failures int) (func() error, *int) {
+		calls := new(int)
+		return func() error {
+			*calls++
+			if *calls <= failures {
+				return err
+			}
+			return nil
+		}, calls
+	}
+
+	// transient errors are retried
+	f, calls := failing(Transient(errors.New("503")), 2)
+	assert.NoError(t, policy.do(context.Background(), "entity/check", f))
+	assert.Equal(t, 3, *calls)
+
+	// until the attempts run out
+	f, calls = failing(Transient(errors.New("503")), 5)
+	assert.Error(t, policy.do(context.Background(), "entity/check", f))
+	assert.Equal(t, 3, *calls)
+
+	// other errors are not
+	f, calls = failing(errors.New("400"), 5)
+	assert.EqualError(t, policy.do(context.Background(), "entity/check", f), "400")
+	assert.Equal(t, 1, *calls)
+
+	// without a policy, nothing is retried
+	f, calls = failing(Transient(errors.New("503")), 5)
+	assert.Error(t, RetryPolicy{}.do(context.Background(), "entity/check", f))
+	assert.Equal(t, 1, *calls)
+}
+
+func TestRetryPolicyDeadline(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, Backoff: time.Hour, MaxBackoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+	err := policy.do(ctx, "entity/check", func() error {
+		calls++
+		return Transient(errors.New("503"))
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestRetryJitter(t *testing.T) {
+	assert.Equal(t, time.Second, jitter(time.Second, 0))
+	assert.Equal(t, time.Second, jitter(time.Second, -1))
+	for i := 0; i < 1000; i++ {
+		delay := jitter(time.Second, 0.5)
+		assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+		assert.LessOrEqual(t, delay, 1500*time.Millisecond)
+
+		// too much jitter would make the delay negative
+		delay = jitter(time.Second, 5)
+		assert.GreaterOrEqual(t, delay, time.Duration(0))
+		assert.LessOrEqual(t, delay, 2*time.Second)
+	}
+}
+
+func TestHandlerRetries(t *testing.T) {
+	clearEnvironment()
+	calls := 0
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, nil, noOp, func(event *corev2.Event) error {
+		calls++
+		if calls < 3 {
+			return Transient(errors.New("service unavailable"))
+		}
+		return nil
+	})
+	handler.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond})
+
+	var exitStatus = -99
+	handler.framework.eventReader = getFileReader("test/event-no-override.json")
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.framework.cmd.SetArgs([]string{})
+	handler.Execute()
+
+	assert.Equal(t, 0, exitStatus)
+	assert.Equal(t, 3, calls)
+}
+
+func TestBatchRetriesPerEventTimeout(t *testing.T) {
+	clearEnvironment()
+	config := defaultHandlerConfig
+	config.Timeout = 1
+	input := strings.Join([]string{
+		compactEventFile(t, "test/event-check-override.json"),
+		compactEventFile(t, "test/event-no-override.json"),
+	}, "\n")
+
+	// the first event uses up most of the handler's timeout, which doesn't
+	// keep the second one from being retried
+	calls := 0
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&config, nil, noOp, func(event *corev2.Event) error {
+		calls++
+		switch {
+		case calls == 1:
+			time.Sleep(900 * time.Millisecond)
+			return nil
+		case calls < 4:
+			return Transient(errors.New("service unavailable"))
+		default:
+			return nil
+		}
+	})
+	handler.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: 60 * time.Millisecond})
+	handler.framework.cmd.SetArgs([]string{"--batch"})
+
+	var exitStatus = -99
+	handler.framework.eventReader = strings.NewReader(input)
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.Execute()
+
+	assert.Equal(t, 0, exitStatus)
+	assert.Equal(t, 4, calls)
+}
@@ -0,0 +1,194 @@
+package sensu
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// eventRunner runs a plugin's event function for many events in a single
+// process. Configuration overrides are applied to the options for the
+// duration of each event, and the options are then restored to the values
+// they were given on the command line or in the environment.
+//
+// The options are shared by all events, so events that carry configuration
+// overrides are run exclusively. Other events may run concurrently, up to the
+// runner's concurrency.
+type eventRunner struct {
+	framework *pluginFramework
+	lock      *eventLock
+	restore   []func()
+}
+
+func newEventRunner(framework *pluginFramework, concurrency int) *eventRunner {
+	return &eventRunner{
+		framework: framework,
+		lock:      newEventLock(concurrency),
+		restore:   framework.snapshotOptions(),
+	}
+}
+
+// run validates event and runs the event function for it.
+func (r *eventRunner) run(ctx context.Context, event *corev2.Event, out io.Writer) (int, error) {
+	return r.runFunc(ctx, event, out, r.framework.pluginEventFunction)
+}
+
+// runFunc validates event and runs f for it. It gives up when ctx is done,
+// whether it is waiting for its turn or for f to return. f is given ctx, and
+// should stop when it is done; until it does, the event keeps its turn.
+func (r *eventRunner) runFunc(ctx context.Context, event *corev2.Event, out io.Writer,
+	f func(context.Context, *corev2.Event, io.Writer) (int, error)) (int, error) {
+	p := r.framework
+	if p.eventValidation {
+		if err := validateEvent(event); err != nil {
+			return p.errorExitStatus, err
+		}
+	}
+
+	exclusive := r.hasOverrides(event)
+	if err := r.lock.acquire(ctx, exclusive); err != nil {
+		return p.errorExitStatus, fmt.Errorf("event %s was not processed: %w", EventKey(event), err)
+	}
+
+	type result struct {
+		status int
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer r.lock.release(exclusive)
+		if exclusive {
+			defer r.reset()
+			if err := configurationOverrides(p.config, p.allOptions(), event, p.verbose); err != nil {
+				done <- result{p.errorExitStatus, err}
+				return
+			}
+			if err := p.validateAllowRestrict(); err != nil {
+				done <- result{p.errorExitStatus, err}
+				return
+			}
+		}
+		status, err := p.safeCall(func() (int, error) {
+			return f(ctx, event, out)
+		})
+		done <- result{status, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.status, res.err
+	case <-ctx.Done():
+		return p.errorExitStatus, fmt.Errorf("event %s was not processed in time: %w", EventKey(event), ctx.Err())
+	}
+}
+
+// hasOverrides tells if the event has any annotations in the plugin's keyspace.
+func (r *eventRunner) hasOverrides(event *corev2.Event) bool {
+	p := r.framework
+	if !p.configurationOverrides || p.config.Keyspace == "" {
+		return false
+	}
+	prefix := strings.ToLower(strings.TrimSuffix(p.config.Keyspace, "/")) + "/"
+	var annotations []map[string]string
+	if event.Check != nil {
+		annotations = append(annotations, event.Check.Annotations)
+	}
+	if event.Entity != nil {
+		annotations = append(annotations, event.Entity.Annotations)
+	}
+	for _, m := range annotations {
+		for key := range m {
+			if strings.HasPrefix(strings.ToLower(key), prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *eventRunner) reset() {
+	restoreOptions(r.restore)
+}
+
+// snapshotOptions returns functions that restore the options to their
+// current values.
+func (p *pluginFramework) snapshotOptions() []func() {
+	var restore []func()
+	for _, opt := range p.allOptions() {
+		switch s := opt.(type) {
+		case optionSnapshotter:
+			restore = append(restore, s.snapshot())
+		case Snapshotter:
+			restore = append(restore, s.Snapshot())
+		}
+	}
+	return restore
+}
+
+func restoreOptions(restore []func()) {
+	for _, f := range restore {
+		f()
+	}
+}
+
+// eventLock is a readers-writer lock whose acquisition can be cancelled.
+// Readers hold one of its tokens, so at most as many readers as it has tokens
+// hold it at the same time. A writer holds all of them.
+type eventLock struct {
+	writer chan struct{}
+	tokens chan struct{}
+}
+
+func newEventLock(concurrency int) *eventLock {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &eventLock{
+		writer: make(chan struct{}, 1),
+		tokens: make(chan struct{}, concurrency),
+	}
+}
+
+// acquire takes the lock, exclusively or not, unless ctx is done first.
+func (l *eventLock) acquire(ctx context.Context, exclusive bool) error {
+	if !exclusive {
+		select {
+		case l.tokens <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	select {
+	case l.writer <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for i := 0; i < cap(l.tokens); i++ {
+		select {
+		case l.tokens <- struct{}{}:
+		case <-ctx.Done():
+			for ; i > 0; i-- {
+				<-l.tokens
+			}
+			<-l.writer
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// release releases the lock taken by acquire.
+func (l *eventLock) release(exclusive bool) {
+	if !exclusive {
+		<-l.tokens
+		return
+	}
+	for i := 0; i < cap(l.tokens); i++ {
+		<-l.tokens
+	}
+	<-l.writer
+}
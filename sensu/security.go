@@ -28,6 +28,7 @@ func SensuSecurityOptions(config *SecurityConfig) []ConfigOption {
 			Env:      "SENSU_CA_CERT",
 			Argument: "sensu-ca-cert",
 			Usage:    "--sensu-ca-cert /etc/ssl/self-signed-ca.crt",
+			Filename: true,
 		},
 		&PluginConfigOption[bool]{
 			Value:    &config.InsecureSkipVerify,
@@ -0,0 +1,163 @@
+package sensu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// DefaultServeAddress is the address that the serve subcommand listens
+	// on by default.
+	DefaultServeAddress = "127.0.0.1:3060"
+
+	// maxServeEventSize is the largest event accepted by the serve subcommand.
+	maxServeEventSize = 1 << 24
+
+	// serveShutdownTimeout is how long the serve subcommand waits for
+	// requests in flight to complete when it is asked to stop.
+	serveShutdownTimeout = 30 * time.Second
+)
+
+// setupServeCommand adds the serve subcommand. The subcommand shares the
+// plugin's option flags, so it must be set up after them.
+func (p *pluginFramework) setupServeCommand() {
+	var (
+		listen  string
+		timeout time.Duration
+	)
+	defaultTimeout := 10 * time.Second
+	if p.config.Timeout > 0 {
+		defaultTimeout = time.Duration(p.config.Timeout) * time.Second
+	}
+	serve := &cobra.Command{
+		Use:   "serve",
+		Short: "Keep the plugin running, processing the events it receives over HTTP",
+		Long: `Keep the plugin running, processing the events it receives over HTTP.
+
+Events are sent as the body of a POST request. The listen address is either
+host:port, or unix:/path/to/socket for a Unix domain socket. Each event goes
+through the same validation, annotation override and execution steps as an
+event read from stdin.`,
+		SilenceErrors: true,
+		Args:          cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := viper.BindPFlags(cmd.Flags()); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return p.serve(ctx, listen, timeout)
+		},
+	}
+	serve.Flags().AddFlagSet(p.cmd.Flags())
+	serve.Flags().StringVar(&listen, "listen", DefaultServeAddress, "The address to listen on, as host:port or unix:/path/to/socket")
+	serve.Flags().DurationVar(&timeout, "request-timeout", defaultTimeout, "The maximum time allowed for processing each event")
+	serve.Flags().IntVar(&p.serveConcurrency, "concurrency", 1, "The maximum number of events processed at the same time")
+	p.cmd.AddCommand(serve)
+	p.serveCmd = serve
+}
+
+// serve listens on address and processes the events it receives until ctx is
+// cancelled.
+func (p *pluginFramework) serve(ctx context.Context, address string, timeout time.Duration) error {
+	p.exitStatus = p.errorExitStatus
+	network := "tcp"
+	if strings.HasPrefix(address, "unix:") {
+		network, address = "unix", strings.TrimPrefix(address, "unix:")
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	return p.serveListener(ctx, listener, timeout)
+}
+
+func (p *pluginFramework) serveListener(ctx context.Context, listener net.Listener, timeout time.Duration) error {
+	if err := p.validateAllowRestrict(); err != nil {
+		_ = listener.Close()
+		return err
+	}
+	runner := newEventRunner(p, p.serveConcurrency)
+	server := &http.Server{
+		Handler:           http.TimeoutHandler(p.serveHandler(runner), timeout, "timed out processing event\n"),
+		ReadHeaderTimeout: timeout,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+	log.Printf("serving %s on %s", p.config.Name, listener.Addr())
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down %s", p.config.Name)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	p.exitStatus = 0
+	return nil
+}
+
+// serveHandler processes events POSTed to it. The output of the plugin, if
+// any, is the body of the response. Each event is processed with the
+// request's context, which is done when the request times out.
+func (p *pluginFramework) serveHandler(runner *eventRunner) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "events must be sent with POST", http.StatusMethodNotAllowed)
+			return
+		}
+		event := new(corev2.Event)
+		if err := json.NewDecoder(io.LimitReader(req.Body, maxServeEventSize)).Decode(event); err != nil {
+			http.Error(w, fmt.Sprintf("failed to unmarshal event: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		out := new(bytes.Buffer)
+		status, err := runner.run(req.Context(), event, out)
+		if err != nil {
+			log.Printf("error processing event %s: %s", EventKey(event), err)
+			code := http.StatusInternalServerError
+			var verr ErrValidationFailed
+			if errors.As(err, &verr) {
+				code = http.StatusUnprocessableEntity
+			}
+			http.Error(w, fmt.Sprintf("exit status %d: %s", status, err), code)
+			return
+		}
+		if out.Len() == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = out.WriteTo(w)
+	})
+}
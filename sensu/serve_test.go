@@ -0,0 +1,232 @@
+package sensu
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// startServe serves the plugin on a random local port, returning its URL and
+// a function that stops it and returns the result of serving.
+func startServe(t *testing.T, framework *pluginFramework, timeout time.Duration) (string, func() error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- framework.serveListener(ctx, listener, timeout)
+	}()
+	return "http://" + listener.Addr().String(), func() error {
+		cancel()
+		return <-errs
+	}
+}
+
+func postEventFile(t *testing.T, url, file string) (int, string) {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	resp, err := http.Post(url, "application/json", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestServeHandler(t *testing.T) {
+	clearEnvironment()
+	values := handlerValues{}
+	var seen []string
+	handler := NewHandler(&defaultHandlerConfig, getHandlerOptions(&values),
+		func(event *corev2.Event) error {
+			if event.Check.Status == 3 {
+				return errors.New("unknown status")
+			}
+			return nil
+		}, func(event *corev2.Event) error {
+			seen = append(seen, values.arg1)
+			return nil
+		})
+
+	url, stop := startServe(t, &handler.framework, time.Second)
+
+	code, _ := postEventFile(t, url, "test/event-check-override.json")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = postEventFile(t, url, "test/event-no-override.json")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, body := postEventFile(t, url, "test/event-invalid-json.json")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, "failed to unmarshal event")
+	code, _ = postEventFile(t, url, "test/event-invalid-check.json")
+	assert.Equal(t, http.StatusInternalServerError, code)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	assert.NoError(t, stop())
+	assert.Equal(t, 0, handler.framework.exitStatus)
+	assert.Equal(t, []string{"value-check1", "Default1"}, seen)
+}
+
+func TestServeMutator(t *testing.T) {
+	clearEnvironment()
+	mutator := NewMutator(&defaultMutatorConfig, getMutatorVales(&mutatorValues{}),
+		func(event *corev2.Event) error {
+			return nil
+		}, func(event *corev2.Event) (*corev2.Event, error) {
+			event.Check.Output = "mutated"
+			return event, nil
+		})
+
+	url, stop := startServe(t, &mutator.framework, time.Second)
+	code, body := postEventFile(t, url, "test/event-no-override.json")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, strings.Contains(body, `"output":"mutated"`), body)
+	assert.NoError(t, stop())
+}
+
+func TestServeTimeout(t *testing.T) {
+	clearEnvironment()
+	release := make(chan struct{})
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, nil, noOp, func(*corev2.Event) error {
+		<-release
+		return nil
+	})
+
+	url, stop := startServe(t, &handler.framework, 50*time.Millisecond)
+	code, body := postEventFile(t, url, "test/event-no-override.json")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "timed out")
+	close(release)
+	assert.NoError(t, stop())
+}
+
+func TestServeTimeoutWithOverrides(t *testing.T) {
+	clearEnvironment()
+	release := make(chan struct{})
+	values := handlerValues{}
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, getHandlerOptions(&values), noOp, func(event *corev2.Event) error {
+		if values.arg1 == "value-check1" {
+			<-release
+		}
+		return nil
+	})
+
+	url, stop := startServe(t, &handler.framework, 50*time.Millisecond)
+
+	// the event with overrides is stuck, holding the options
+	code, _ := postEventFile(t, url, "test/event-check-override.json")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	// later events time out waiting for their turn, rather than blocking
+	start := time.Now()
+	code, _ = postEventFile(t, url, "test/event-no-override.json")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// and are processed once the stuck event returns
+	close(release)
+	assert.Eventually(t, func() bool {
+		code, _ := postEventFile(t, url, "test/event-no-override.json")
+		return code == http.StatusNoContent
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, stop())
+}
+
+func TestServeRetriesStopAtRequestTimeout(t *testing.T) {
+	clearEnvironment()
+	var calls int32
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, nil, noOp, func(*corev2.Event) error {
+		atomic.AddInt32(&calls, 1)
+		return Transient(errors.New("service unavailable"))
+	})
+	handler.SetRetryPolicy(RetryPolicy{MaxAttempts: 100, Backoff: 35 * time.Millisecond, Multiplier: 1})
+
+	// retries that would outlast the request are given up; the last retry
+	// is well before the timeout, so that the handler's error is returned
+	url, stop := startServe(t, &handler.framework, 100*time.Millisecond)
+	code, body := postEventFile(t, url, "test/event-no-override.json")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Contains(t, body, "service unavailable")
+	assert.NoError(t, stop())
+	assert.Less(t, atomic.LoadInt32(&calls), int32(10))
+}
+
+func TestServeConcurrency(t *testing.T) {
+	for _, concurrency := range []int{1, 3} {
+		clearEnvironment()
+		var running, max int32
+		noOp := func(*corev2.Event) error { return nil }
+		handler := NewHandler(&defaultHandlerConfig, nil, noOp, func(*corev2.Event) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+		handler.framework.serveConcurrency = concurrency
+
+		url, stop := startServe(t, &handler.framework, 5*time.Second)
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				code, _ := postEventFile(t, url, "test/event-no-override.json")
+				assert.Equal(t, http.StatusNoContent, code)
+			}()
+		}
+		wg.Wait()
+		assert.NoError(t, stop())
+		assert.LessOrEqual(t, atomic.LoadInt32(&max), int32(concurrency))
+		if concurrency > 1 {
+			assert.Greater(t, atomic.LoadInt32(&max), int32(1))
+		}
+	}
+}
+
+func TestServeCommandFlags(t *testing.T) {
+	values := handlerValues{}
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, getHandlerOptions(&values), noOp, noOp)
+	serve := handler.framework.serveCmd
+	if assert.NotNil(t, serve) {
+		for _, name := range []string{"string", "uint64", "bool", "dry-run", "listen", "request-timeout", "concurrency"} {
+			assert.NotNil(t, serve.Flags().Lookup(name), name)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package sensu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu/state"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Spool defaults.
+const (
+	DefaultSpoolMaxAge     = 24 * time.Hour
+	DefaultSpoolMaxEvents  = 1000
+	DefaultSpoolMaxBytes   = 64 << 20
+	DefaultSpoolFlushLimit = 100
+)
+
+const (
+	// spoolSuffix ends the names of spooled event files.
+	spoolSuffix = ".json"
+
+	// spoolClaimSuffix is added to the name of a spooled event file while it
+	// is being handled, so that other processes flushing the spool skip it.
+	spoolClaimSuffix = ".flushing"
+
+	// spoolStaleClaim is how long a claimed event can be handled before it
+	// is assumed that the process handling it died, and it is spooled again.
+	spoolStaleClaim = 10 * time.Minute
+)
+
+// SpoolOptions configure a handler's spool.
+type SpoolOptions struct {
+	// Dir is the spool directory. It defaults to a directory named after the
+	// handler, in state.DefaultDir().
+	Dir string
+
+	// MaxAge is how long an event is kept in the spool. Older events are
+	// dropped. It defaults to DefaultSpoolMaxAge.
+	MaxAge time.Duration
+
+	// MaxEvents is the maximum number of events in the spool. When it is
+	// full, the oldest events are dropped. It defaults to
+	// DefaultSpoolMaxEvents.
+	MaxEvents int
+
+	// MaxBytes is the maximum total size of the events in the spool. When
+	// it is full, the oldest events are dropped. It defaults to
+	// DefaultSpoolMaxBytes.
+	MaxBytes int64
+
+	// FlushLimit is the maximum number of spooled events handled by each
+	// run of the handler. It defaults to DefaultSpoolFlushLimit. The
+	// flush-spool subcommand handles all of them.
+	FlushLimit int
+}
+
+// EnableSpool makes the handler keep the events that it failed to handle
+// with a transient error in a spool directory. Before a later run of the
+// handler handles the event it was given, it handles spooled events, oldest
+// first, up to FlushLimit and until its timeout expires. If older events for
+// the same check are left in the spool, the event is spooled behind them
+// instead, so that events for a check are always handled in order. The
+// flush-spool subcommand, which EnableSpool adds, only handles the spooled
+// events, with no limit other than the timeout.
+//
+// Spooled events are handled with the options given on the command line and
+// in the environment, and their own annotation overrides. They go through the
+// handler's validation again, but not its filters. An event that fails again
+// with a transient error stays in the spool, and the rest of the spool is
+// left for a later run; other failures drop the event. Nothing is spooled, or
+// removed from the spool, with --dry-run.
+func (h *Handler) EnableSpool(options SpoolOptions) {
+	if options.Dir == "" {
+		options.Dir = filepath.Join(state.DefaultDir(), h.framework.config.Name+"-spool")
+	}
+	if options.MaxAge <= 0 {
+		options.MaxAge = DefaultSpoolMaxAge
+	}
+	if options.MaxEvents <= 0 {
+		options.MaxEvents = DefaultSpoolMaxEvents
+	}
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = DefaultSpoolMaxBytes
+	}
+	if options.FlushLimit <= 0 {
+		options.FlushLimit = DefaultSpoolFlushLimit
+	}
+	h.spool = &spool{options: options, now: time.Now}
+	h.setupFlushSpoolCommand()
+}
+
+// setupFlushSpoolCommand adds the flush-spool subcommand. It shares the flags
+// of the serve subcommand, other than the ones that are specific to serving
+// and the filters, which spooled events have already been through.
+func (h *Handler) setupFlushSpoolCommand() {
+	p := &h.framework
+	if p.cmd == nil {
+		return
+	}
+	for _, cmd := range p.cmd.Commands() {
+		if cmd.Name() == "flush-spool" {
+			return
+		}
+	}
+	flush := &cobra.Command{
+		Use:   "flush-spool",
+		Short: "Handle the events that earlier runs of the handler failed to handle",
+		Long: `Handle the events that earlier runs of the handler failed to handle, oldest
+first. Flushing stops at the first event that fails again with a transient
+error, which stays in the spool, or when the handler's timeout expires.`,
+		SilenceErrors: true,
+		Args:          cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := viper.BindPFlags(cmd.Flags()); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			p.exitStatus = p.errorExitStatus
+			if err := p.validateAllowRestrict(); err != nil {
+				return err
+			}
+			ctx, cancel := p.timeoutContext()
+			defer cancel()
+			if _, err := h.flushSpool(ctx, 0); err != nil {
+				return err
+			}
+			p.exitStatus = 0
+			return nil
+		},
+	}
+	flags := p.cmd.Flags()
+	if p.serveCmd != nil {
+		flags = p.serveCmd.Flags()
+	}
+	flags.VisitAll(func(flag *pflag.Flag) {
+		switch flag.Name {
+		case "listen", "request-timeout", "concurrency":
+			// specific to serving
+		case "allow-filter", "deny-filter":
+			// spooled events have already been through the filters
+		default:
+			flush.Flags().AddFlag(flag)
+		}
+	})
+	p.cmd.AddCommand(flush)
+}
+
+// flushSpool handles at most limit spooled events, or all of them if limit
+// isn't positive, until ctx is done. It returns how many were handled.
+func (h *Handler) flushSpool(ctx context.Context, limit int) (int, error) {
+	runner := newEventRunner(&h.framework, 1)
+	defer runner.reset()
+	handled, err := h.spool.flush(ctx, limit, h.dryRun, func(ctx context.Context, event *corev2.Event) error {
+		_, err := runner.runFunc(ctx, event, nil, h.handleSpooled)
+		return err
+	})
+	if handled > 0 {
+		log.Printf("handled %d spooled events", handled)
+	}
+	return handled, err
+}
+
+// handleSpooled validates and handles an event from the spool.
+func (h *Handler) handleSpooled(ctx context.Context, event *corev2.Event, _ io.Writer) (int, error) {
+	if status, err := h.validateInput(event); err != nil {
+		return status, err
+	}
+	if err := h.execute(ctx, event); err != nil {
+		return 1, fmt.Errorf("error executing handler: %w", err)
+	}
+	return 0, nil
+}
+
+// spoolEvent adds an event that the handler failed to handle to the spool.
+func (h *Handler) spoolEvent(event *corev2.Event) {
+	if h.spool == nil || h.dryRun {
+		return
+	}
+	if err := h.spool.add(event); err != nil {
+		log.Printf("failed to spool event %s: %s", EventKey(event), err)
+		return
+	}
+	log.Printf("spooled event %s, to be handled again later", EventKey(event))
+}
+
+// spool is a directory of events, one JSON file per event. File names start
+// with the time the event was spooled, so that they sort oldest first.
+type spool struct {
+	options SpoolOptions
+	now     func() time.Time
+}
+
+// spoolEntry is an event file in the spool.
+type spoolEntry struct {
+	path    string
+	spooled time.Time
+	size    int64
+}
+
+// holds tells if the spool has an event with the given key, other than the
+// events claimed by another process.
+func (s *spool) holds(key string) bool {
+	entries, err := s.entries()
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		var event corev2.Event
+		data, err := os.ReadFile(entry.path)
+		if err == nil {
+			err = json.Unmarshal(data, &event)
+		}
+		if err == nil && EventKey(&event) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// add writes event to the spool, and then drops the oldest events if the
+// spool is over its limits.
+func (s *spool) add(event *corev2.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.options.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create spool directory: %s", err)
+	}
+	name := fmt.Sprintf("%020d-%s%s", s.now().UnixNano(), uuid.New(), spoolSuffix)
+	f, err := os.CreateTemp(s.options.Dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(s.options.Dir, name))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return s.trim()
+}
+
+// trim drops expired events, and the oldest events while the spool is over
+// its limits.
+func (s *spool) trim() error {
+	entries, err := s.entries()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, entry := range entries {
+		size += entry.size
+	}
+	count := len(entries)
+	for _, entry := range entries {
+		expired := s.expired(entry)
+		if !expired && count <= s.options.MaxEvents && size <= s.options.MaxBytes {
+			break
+		}
+		if err := os.Remove(entry.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		count--
+		size -= entry.size
+		if expired {
+			log.Printf("dropped spooled event %s: older than %s", filepath.Base(entry.path), s.options.MaxAge)
+		} else {
+			log.Printf("dropped spooled event %s: the spool is full", filepath.Base(entry.path))
+		}
+	}
+	return nil
+}
+
+// entries lists the events in the spool, oldest first. Events claimed by a
+// process that died are returned to the spool.
+func (s *spool) entries() ([]spoolEntry, error) {
+	files, err := os.ReadDir(s.options.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []spoolEntry
+	for _, file := range files {
+		name := file.Name()
+		path := filepath.Join(s.options.Dir, name)
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		if strings.HasSuffix(name, spoolSuffix+spoolClaimSuffix) {
+			if s.now().Sub(info.ModTime()) < spoolStaleClaim {
+				continue
+			}
+			unclaimed := strings.TrimSuffix(path, spoolClaimSuffix)
+			if err := os.Rename(path, unclaimed); err != nil {
+				continue
+			}
+			name, path = filepath.Base(unclaimed), unclaimed
+		}
+		if !strings.HasSuffix(name, spoolSuffix) {
+			continue
+		}
+		prefix, _, _ := strings.Cut(name, "-")
+		nanos, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, spoolEntry{path: path, spooled: time.Unix(0, nanos), size: info.Size()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].path < entries[j].path
+	})
+	return entries, nil
+}
+
+func (s *spool) expired(entry spoolEntry) bool {
+	return s.now().Sub(entry.spooled) > s.options.MaxAge
+}
+
+// flush handles the spooled events, oldest first, with handle. An event is
+// removed from the spool once it is handled. Flushing stops at the first
+// event that fails with a transient error, or when ctx is done, and that
+// event stays in the spool. Events that fail with other errors will never be
+// handled, and are dropped. At most limit events are handled, if limit is
+// positive. With dryRun, events are handled, but not removed from the spool.
+// flush returns the number of events that were handled.
+func (s *spool) flush(ctx context.Context, limit int, dryRun bool, handle func(context.Context, *corev2.Event) error) (int, error) {
+	if err := s.trim(); err != nil {
+		return 0, err
+	}
+	entries, err := s.entries()
+	if err != nil {
+		return 0, err
+	}
+	var handled, attempted int
+	for _, entry := range entries {
+		if limit > 0 && attempted >= limit {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return handled, fmt.Errorf("stopped handling spooled events: %w", err)
+		}
+		path := entry.path
+		if !dryRun {
+			claimed := path + spoolClaimSuffix
+			if err := os.Rename(path, claimed); err != nil {
+				// another process is handling the event
+				continue
+			}
+			now := s.now()
+			_ = os.Chtimes(claimed, now, now)
+			path = claimed
+		}
+
+		var event corev2.Event
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &event)
+		}
+		if err != nil {
+			log.Printf("dropped spooled event %s: %s", filepath.Base(entry.path), err)
+			s.remove(path, dryRun)
+			continue
+		}
+
+		attempted++
+		if err := handle(ctx, &event); err != nil {
+			if ctx.Err() == nil && !IsTransient(err) {
+				log.Printf("dropped spooled event %s: %s", EventKey(&event), err)
+				s.remove(path, dryRun)
+				continue
+			}
+			if !dryRun {
+				if renameErr := os.Rename(path, entry.path); renameErr != nil {
+					log.Printf("failed to return event %s to the spool: %s", EventKey(&event), renameErr)
+				}
+			}
+			return handled, fmt.Errorf("failed to handle spooled event %s: %w", EventKey(&event), err)
+		}
+		s.remove(path, dryRun)
+		handled++
+	}
+	return handled, nil
+}
+
+func (s *spool) remove(path string, dryRun bool) {
+	if dryRun {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("failed to remove spooled event: %s", err)
+	}
+}
@@ -0,0 +1,419 @@
+package sensu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func spooledFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+spoolSuffix))
+	assert.NoError(t, err)
+	return files
+}
+
+func spoolTestEvent(name string) *corev2.Event {
+	event := corev2.FixtureEvent("entity", name)
+	event.Check.Status = 2
+	return event
+}
+
+func TestHandlerSpool(t *testing.T) {
+	clearEnvironment()
+	dir := t.TempDir()
+	var handled []string
+	failing := true
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, nil, noOp, func(event *corev2.Event) error {
+		if failing {
+			return Transient(errors.New("service unavailable"))
+		}
+		handled = append(handled, EventKey(event))
+		return nil
+	})
+	handler.EnableSpool(SpoolOptions{Dir: dir})
+
+	var exitStatus = -99
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.framework.errorLogFunction = func(format string, a ...interface{}) {}
+	handler.framework.cmd.SetArgs([]string{})
+
+	// the event fails, and is spooled
+	handler.framework.eventReader = getFileReader("test/event-no-override.json")
+	handler.Execute()
+	assert.Equal(t, 1, exitStatus)
+	assert.Len(t, spooledFiles(t, dir), 1)
+
+	// the spooled events are handled before the next one
+	failing = false
+	assert.NoError(t, handler.spool.add(spoolTestEvent("check2")))
+	handler.framework.eventReader = getFileReader("test/event-no-override.json")
+	handler.Execute()
+	assert.Equal(t, 0, exitStatus)
+	assert.Equal(t, []string{"webserver01/check-nginx", "entity/check2", "webserver01/check-nginx"}, handled)
+	assert.Empty(t, spooledFiles(t, dir))
+
+	// nothing is spooled in dry run mode
+	failing = true
+	handler.framework.eventReader = getFileReader("test/event-no-override.json")
+	handler.framework.cmd.SetArgs([]string{"--dry-run"})
+	handler.Execute()
+	assert.Equal(t, 1, exitStatus)
+	assert.Empty(t, spooledFiles(t, dir))
+}
+
+func TestFlushSpoolCommand(t *testing.T) {
+	clearEnvironment()
+	dir := t.TempDir()
+	var handled []string
+	failOn := ""
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, nil, noOp, func(event *corev2.Event) error {
+		if event.Check.Name == failOn {
+			return Transient(errors.New("service unavailable"))
+		}
+		handled = append(handled, event.Check.Name)
+		return nil
+	})
+	handler.EnableSpool(SpoolOptions{Dir: dir})
+	for _, name := range []string{"check1", "check2", "check3"} {
+		assert.NoError(t, handler.spool.add(spoolTestEvent(name)))
+	}
+
+	var exitStatus = -99
+	var errorStr string
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.framework.errorLogFunction = func(format string, a ...interface{}) {
+		errorStr = format
+	}
+
+	// flushing stops at the first event that fails
+	failOn = "check2"
+	handler.framework.cmd.SetArgs([]string{"flush-spool"})
+	handler.Execute()
+	assert.Equal(t, 1, exitStatus)
+	assert.NotEmpty(t, errorStr)
+	assert.Equal(t, []string{"check1"}, handled)
+	assert.Len(t, spooledFiles(t, dir), 2)
+
+	failOn = ""
+	handler.framework.cmd.SetArgs([]string{"flush-spool"})
+	handler.Execute()
+	assert.Equal(t, 0, exitStatus)
+	assert.Equal(t, []string{"check1", "check2", "check3"}, handled)
+	assert.Empty(t, spooledFiles(t, dir))
+}
+
+func TestFlushSpoolCommandChecks(t *testing.T) {
+	clearEnvironment()
+	dir := t.TempDir()
+	config := defaultHandlerConfig
+	config.Timeout = 1
+	restricted := restrictedIntOpt
+	var restrictedValue int
+	restricted.Value = &restrictedValue
+	release := make(chan struct{})
+	defer close(release)
+	var handled []string
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&config, []ConfigOption{&restricted}, noOp, func(event *corev2.Event) error {
+		if event.Check.Name == "check1" {
+			<-release
+		}
+		handled = append(handled, event.Check.Name)
+		return nil
+	})
+	handler.EnableSpool(SpoolOptions{Dir: dir})
+	assert.NoError(t, handler.spool.add(spoolTestEvent("check1")))
+
+	var exitStatus = -99
+	var errorStr string
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.framework.errorLogFunction = func(format string, a ...interface{}) {
+		errorStr = fmt.Sprintf(format, a...)
+	}
+
+	// the filters don't apply to spooled events
+	flush, _, err := handler.framework.cmd.Find([]string{"flush-spool"})
+	assert.NoError(t, err)
+	assert.Nil(t, flush.Flags().Lookup("allow-filter"))
+	assert.Nil(t, flush.Flags().Lookup("deny-filter"))
+	assert.NotNil(t, flush.Flags().Lookup("restrictedint"))
+
+	// invalid options are reported before any event is handled
+	handler.framework.cmd.SetArgs([]string{"flush-spool", "--restrictedint", "45"})
+	handler.Execute()
+	assert.Equal(t, 1, exitStatus)
+	assert.Contains(t, errorStr, "restrictedint: value not allowed to be 45")
+	assert.Len(t, spooledFiles(t, dir), 1)
+
+	// flushing stops when the handler's timeout expires
+	start := time.Now()
+	handler.framework.cmd.SetArgs([]string{"flush-spool", "--restrictedint", "1"})
+	handler.Execute()
+	assert.Equal(t, 1, exitStatus)
+	assert.Contains(t, errorStr, "deadline exceeded")
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Empty(t, handled)
+}
+
+func TestSpoolLimits(t *testing.T) {
+	now := time.Now()
+	s := &spool{
+		options: SpoolOptions{Dir: t.TempDir(), MaxAge: time.Hour, MaxEvents: 2, MaxBytes: 1 << 20},
+		now:     func() time.Time { return now },
+	}
+	for _, name := range []string{"check1", "check2", "check3"} {
+		now = now.Add(time.Minute)
+		assert.NoError(t, s.add(spoolTestEvent(name)))
+	}
+
+	// the oldest event was dropped
+	var flushed []string
+	handle := func(_ context.Context, event *corev2.Event) error {
+		flushed = append(flushed, event.Check.Name)
+		return nil
+	}
+	handled, err := s.flush(context.Background(), 0, true, handle)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, handled)
+	assert.Equal(t, []string{"check2", "check3"}, flushed)
+
+	// expired events are dropped
+	now = now.Add(time.Hour - time.Second)
+	flushed = nil
+	_, err = s.flush(context.Background(), 0, false, handle)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"check3"}, flushed)
+
+	// events over the size limit are dropped
+	s.options.MaxBytes = 10
+	assert.NoError(t, s.add(spoolTestEvent("check4")))
+	assert.Empty(t, spooledFiles(t, s.options.Dir))
+}
+
+func TestSpoolStaleClaim(t *testing.T) {
+	now := time.Now()
+	s := &spool{
+		options: SpoolOptions{Dir: t.TempDir(), MaxAge: time.Hour, MaxEvents: 10, MaxBytes: 1 << 20},
+		now:     func() time.Time { return now },
+	}
+	assert.NoError(t, s.add(spoolTestEvent("check1")))
+	files := spooledFiles(t, s.options.Dir)
+	if !assert.Len(t, files, 1) {
+		return
+	}
+
+	// an event claimed by another process is skipped
+	claimed := files[0] + spoolClaimSuffix
+	assert.NoError(t, os.Rename(files[0], claimed))
+	entries, err := s.entries()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// until its claim is stale
+	now = now.Add(spoolStaleClaim + time.Second)
+	entries, err = s.entries()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, files, spooledFiles(t, s.options.Dir))
+}
+
+func TestSpoolDropsInvalidEvents(t *testing.T) {
+	s := &spool{
+		options: SpoolOptions{Dir: t.TempDir(), MaxAge: time.Hour, MaxEvents: 10, MaxBytes: 1 << 20},
+		now:     time.Now,
+	}
+	assert.NoError(t, s.add(spoolTestEvent("check1")))
+	assert.NoError(t, s.add(spoolTestEvent("check2")))
+
+	// an event that fails validation will never be handled, so it is dropped
+	var flushed []string
+	handled, err := s.flush(context.Background(), 0, false, func(_ context.Context, event *corev2.Event) error {
+		if event.Check.Name == "check1" {
+			return validationFailed(errors.New("invalid"))
+		}
+		flushed = append(flushed, event.Check.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, handled)
+	assert.Equal(t, []string{"check2"}, flushed)
+	assert.Empty(t, spooledFiles(t, s.options.Dir))
+}
+
+func TestSpoolNonTransientFailures(t *testing.T) {
+	clearEnvironment()
+	dir := t.TempDir()
+	var calls int
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, nil, noOp, func(event *corev2.Event) error {
+		calls++
+		if event.Check.Name == "check1" {
+			return errors.New("bad request")
+		}
+		return nil
+	})
+	handler.EnableSpool(SpoolOptions{Dir: dir})
+
+	// an event that failed with an error that isn't transient isn't spooled
+	status, err := handler.handle(context.Background(), spoolTestEvent("check1"), nil)
+	assert.Equal(t, 1, status)
+	assert.Error(t, err)
+	assert.Empty(t, spooledFiles(t, dir))
+
+	// a spooled event that fails with such an error is dropped, rather than
+	// blocking the spool
+	assert.NoError(t, handler.spool.add(spoolTestEvent("check1")))
+	assert.NoError(t, handler.spool.add(spoolTestEvent("check2")))
+	calls = 0
+	handled, err := handler.flushSpool(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, handled)
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, spooledFiles(t, dir))
+}
+
+func TestSpoolUsesCommandLineOptions(t *testing.T) {
+	clearEnvironment()
+	dir := t.TempDir()
+	values := handlerValues{}
+	var seen []string
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, getHandlerOptions(&values), noOp, func(event *corev2.Event) error {
+		seen = append(seen, values.arg1)
+		return nil
+	})
+	handler.EnableSpool(SpoolOptions{Dir: dir})
+	assert.NoError(t, handler.spool.add(corev2.FixtureEvent("entity", "check")))
+
+	var exitStatus = -99
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.framework.eventReader = getFileReader("test/event-check-override.json")
+	handler.framework.cmd.SetArgs([]string{})
+	handler.Execute()
+
+	// the spooled event doesn't get the overrides of the event from stdin
+	assert.Equal(t, 0, exitStatus)
+	assert.Equal(t, []string{"Default1", "value-check1"}, seen)
+	assert.Empty(t, spooledFiles(t, dir))
+}
+
+func TestSpoolFlushBudget(t *testing.T) {
+	s := &spool{
+		options: SpoolOptions{Dir: t.TempDir(), MaxAge: time.Hour, MaxEvents: 10, MaxBytes: 1 << 20},
+		now:     time.Now,
+	}
+	for _, name := range []string{"check1", "check2", "check3"} {
+		assert.NoError(t, s.add(spoolTestEvent(name)))
+	}
+	var flushed []string
+	handle := func(_ context.Context, event *corev2.Event) error {
+		flushed = append(flushed, event.Check.Name)
+		return nil
+	}
+
+	// at most limit events are handled
+	handled, err := s.flush(context.Background(), 2, false, handle)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, handled)
+	assert.Equal(t, []string{"check1", "check2"}, flushed)
+
+	// none are once ctx is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handled, err = s.flush(ctx, 0, false, handle)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, handled)
+	assert.Len(t, spooledFiles(t, s.options.Dir), 1)
+
+	// an event that runs out of time stays in the spool
+	ctx, cancel = context.WithCancel(context.Background())
+	handled, err = s.flush(ctx, 0, false, func(ctx context.Context, event *corev2.Event) error {
+		cancel()
+		return ctx.Err()
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 0, handled)
+	assert.Len(t, spooledFiles(t, s.options.Dir), 1)
+}
+
+func TestSpoolOrder(t *testing.T) {
+	clearEnvironment()
+	dir := t.TempDir()
+	var handled []uint32
+	failing := false
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, nil, noOp, func(event *corev2.Event) error {
+		if failing && event.Check.Status != 0 {
+			return Transient(errors.New("service unavailable"))
+		}
+		handled = append(handled, event.Check.Status)
+		return nil
+	})
+	handler.EnableSpool(SpoolOptions{Dir: dir})
+
+	var exitStatus = -99
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.framework.errorLogFunction = func(format string, a ...interface{}) {}
+	handler.framework.cmd.SetArgs([]string{})
+
+	// the spooled incident is handled before the newer resolution of the
+	// same check
+	assert.NoError(t, handler.spool.add(spoolTestEvent("check-nginx")))
+	handler.framework.eventReader = strings.NewReader(resolutionEvent(t))
+	handler.Execute()
+	assert.Equal(t, 0, exitStatus)
+	assert.Equal(t, []uint32{2, 0}, handled)
+	assert.Empty(t, spooledFiles(t, dir))
+
+	// while the incident can't be handled, the resolution is spooled behind
+	// it, rather than overtaking it
+	handled = nil
+	failing = true
+	assert.NoError(t, handler.spool.add(spoolTestEvent("check-nginx")))
+	handler.framework.eventReader = strings.NewReader(resolutionEvent(t))
+	handler.Execute()
+	assert.Equal(t, 1, exitStatus)
+	assert.Empty(t, handled)
+	assert.Len(t, spooledFiles(t, dir), 2)
+
+	// and both are handled in order once the service is back
+	failing = false
+	count, err := handler.flushSpool(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []uint32{2, 0}, handled)
+}
+
+// resolutionEvent returns an OK event for the check of spoolTestEvent.
+func resolutionEvent(t *testing.T) string {
+	t.Helper()
+	event := corev2.FixtureEvent("entity", "check-nginx")
+	event.Check.Status = 0
+	b, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,73 @@
+package state
+
+import (
+	"sort"
+	"strings"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu/metric"
+)
+
+// counterPrefix prefixes the keys of the counter samples saved by Rates.
+const counterPrefix = "counter:"
+
+type counterSample struct {
+	Value float64 `json:"value"`
+	Time  int64   `json:"time"`
+}
+
+// Rates turns counter points into per-second rates, using the samples saved
+// by the previous run, and saves the current samples for the next run.
+// Counters are told apart by their name and tags, which are kept in the
+// returned points. The time of a sample is the time Rates was called.
+//
+// A counter has no rate, and is left out of the result, the first time it is
+// seen, if its previous sample expired, or if it went down, which means that
+// it was reset or wrapped around.
+func Rates(store *Store, points metric.Points) (metric.Points, error) {
+	var rates metric.Points
+	err := store.Update(func(tx *Tx) error {
+		now := tx.now
+		for _, point := range points {
+			if point == nil {
+				continue
+			}
+			key := counterPrefix + pointKey(point)
+			var previous counterSample
+			found, err := tx.Get(key, &previous)
+			if err != nil {
+				return err
+			}
+			if err := tx.Set(key, counterSample{Value: point.Value, Time: now.UnixNano()}); err != nil {
+				return err
+			}
+			elapsed := float64(now.UnixNano()-previous.Time) / 1e9
+			if !found || elapsed <= 0 || point.Value < previous.Value {
+				continue
+			}
+			rates = append(rates, &corev2.MetricPoint{
+				Name:      point.Name,
+				Value:     (point.Value - previous.Value) / elapsed,
+				Timestamp: now.Unix(),
+				Tags:      point.Tags,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// pointKey identifies a counter by its name and sorted tags.
+func pointKey(point *corev2.MetricPoint) string {
+	tags := make([]string, 0, len(point.Tags))
+	for _, tag := range point.Tags {
+		if tag != nil {
+			tags = append(tags, tag.Name+"="+tag.Value)
+		}
+	}
+	sort.Strings(tags)
+	return point.Name + "{" + strings.Join(tags, ",") + "}"
+}
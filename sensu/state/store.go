@@ -0,0 +1,388 @@
+// Package state persists small keyed values between runs of a plugin, such as
+// the previous sample of a counter, in a file per plugin.
+package state
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+const (
+	// DefaultLockTimeout is how long a Store waits for the lock on its file,
+	// if Options.LockTimeout is not set.
+	DefaultLockTimeout = 10 * time.Second
+
+	// staleLockAge is the age at which a lock file is assumed to have been
+	// left behind by a plugin that was killed, and is removed.
+	staleLockAge = time.Minute
+
+	lockRetryInterval = 10 * time.Millisecond
+)
+
+// lockRefreshInterval is how often the holder of a lock refreshes it, so
+// that it doesn't become stale.
+var lockRefreshInterval = staleLockAge / 4
+
+// ErrLocked is returned when the lock on a store's file can't be acquired
+// before the lock timeout.
+var ErrLocked = errors.New("state store is locked")
+
+// Options configure a Store.
+type Options struct {
+	// Dir is the directory that holds the store's file. The default is
+	// DefaultDir().
+	Dir string
+
+	// TTL is how long entries are kept after they were last set. Expired
+	// entries are treated as missing, and removed when the store is next
+	// saved. Zero means entries never expire.
+	TTL time.Duration
+
+	// LockTimeout is how long to wait for the lock on the store's file. The
+	// default is DefaultLockTimeout.
+	LockTimeout time.Duration
+}
+
+// Store holds the state of a plugin. Every operation locks the store's file,
+// so that concurrent runs of the plugin don't overwrite each other's state,
+// and changes are written atomically.
+type Store struct {
+	path        string
+	ttl         time.Duration
+	lockTimeout time.Duration
+	now         func() time.Time
+}
+
+type entry struct {
+	Value   json.RawMessage `json:"value"`
+	Updated time.Time       `json:"updated"`
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// DefaultDir returns the directory that holds state files by default: a
+// sensu-plugins directory in the user's cache directory, or in the temporary
+// directory if the user has none.
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "sensu-plugins")
+}
+
+// Open opens the store of the plugin named plugin, creating its directory if
+// necessary. The file is only created when a value is first set.
+func Open(plugin string, options Options) (*Store, error) {
+	if plugin == "" {
+		return nil, errors.New("state store needs a plugin name")
+	}
+	dir := options.Dir
+	if dir == "" {
+		dir = DefaultDir()
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %s", err)
+	}
+	lockTimeout := options.LockTimeout
+	if lockTimeout <= 0 {
+		lockTimeout = DefaultLockTimeout
+	}
+	return &Store{
+		path:        filepath.Join(dir, unsafeChars.ReplaceAllString(plugin, "_")+".json"),
+		ttl:         options.TTL,
+		lockTimeout: lockTimeout,
+		now:         time.Now,
+	}, nil
+}
+
+// Path returns the path of the store's file.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Get reads the value of key into value, which must be a pointer. It tells
+// if the key was found.
+func (s *Store) Get(key string, value interface{}) (found bool, err error) {
+	err = s.Update(func(tx *Tx) error {
+		found, err = tx.Get(key, value)
+		return err
+	})
+	return found, err
+}
+
+// Set sets the value of key. The value is stored as JSON.
+func (s *Store) Set(key string, value interface{}) error {
+	return s.Update(func(tx *Tx) error {
+		return tx.Set(key, value)
+	})
+}
+
+// Delete removes key.
+func (s *Store) Delete(key string) error {
+	return s.Update(func(tx *Tx) error {
+		tx.Delete(key)
+		return nil
+	})
+}
+
+// Update locks the store and calls fn, for reading and changing several
+// values at once. The changes are saved if fn returns nil, and discarded
+// otherwise.
+func (s *Store) Update(fn func(tx *Tx) error) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tx := &Tx{store: s, now: s.now()}
+	if tx.entries, err = s.load(); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if !tx.changed {
+		return nil
+	}
+	return s.save(tx.entries)
+}
+
+func (s *Store) load() (map[string]entry, error) {
+	entries := make(map[string]entry)
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state: %s", err)
+	}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		// a corrupt file would otherwise break the plugin for good
+		log.Printf("discarding corrupt state %s: %s", s.path, err)
+		return make(map[string]entry), nil
+	}
+	return entries, nil
+}
+
+// save writes entries to a temporary file, which is then renamed over the
+// store's file, so that the file is never partially written.
+func (s *Store) save(entries map[string]entry) error {
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to save state: %s", err)
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save state: %s", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to save state: %s", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to save state: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to save state: %s", err)
+	}
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save state: %s", err)
+	}
+	return nil
+}
+
+// lock acquires the store's lock file. Creating the file with O_EXCL works
+// the same way on every platform. The file holds a token that identifies its
+// holder, who refreshes it while the lock is held; a lock file that hasn't
+// been refreshed for staleLockAge was left behind by a plugin that was
+// killed, and is removed.
+func (s *Store) lock() (unlock func(), err error) {
+	path := s.path + ".lock"
+	token, err := lockToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock state: %s", err)
+	}
+	deadline := time.Now().Add(s.lockTimeout)
+	for {
+		err := createLockFile(path, token)
+		if err == nil {
+			return holdLock(path, token), nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock state: %s", err)
+		}
+		if removeStaleLock(path) {
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// lockToken returns a token that identifies the holder of a lock.
+func lockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%s", os.Getpid(), hex.EncodeToString(b)), nil
+}
+
+func createLockFile(path, token string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(token)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
+
+// holdLock refreshes the lock file at path until the returned function is
+// called, which then removes the file if it still holds token.
+func holdLock(path, token string) (unlock func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if readLockToken(path) != token {
+					return
+				}
+				now := time.Now()
+				_ = os.Chtimes(path, now, now)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		if readLockToken(path) == token {
+			_ = os.Remove(path)
+		}
+	}
+}
+
+// removeStaleLock removes the lock file at path if it is stale, and tells if
+// it did. The file is first moved aside, so that only one of the plugins that
+// find it stale removes it. If a new lock replaced the stale one in the
+// meantime, it is put back.
+func removeStaleLock(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) <= staleLockAge {
+		return false
+	}
+	token := readLockToken(path)
+	aside, err := lockToken()
+	if err != nil {
+		return false
+	}
+	aside = path + "." + aside + ".stale"
+	if err := os.Rename(path, aside); err != nil {
+		return false
+	}
+	info, err = os.Stat(aside)
+	if err == nil && readLockToken(aside) == token && time.Since(info.ModTime()) > staleLockAge {
+		_ = os.Remove(aside)
+		return true
+	}
+	// the lock isn't stale anymore: put it back, unless another lock was
+	// taken since it was moved aside
+	if err := os.Link(aside, path); err != nil {
+		log.Printf("state lock %s was replaced while it was held", path)
+	}
+	_ = os.Remove(aside)
+	return false
+}
+
+func readLockToken(path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// Tx gives access to the values of a locked Store. See Store.Update.
+type Tx struct {
+	store   *Store
+	entries map[string]entry
+	now     time.Time
+	changed bool
+}
+
+// Get reads the value of key into value, which must be a pointer. It tells
+// if the key was found.
+func (tx *Tx) Get(key string, value interface{}) (bool, error) {
+	e, ok := tx.entries[key]
+	if !ok {
+		return false, nil
+	}
+	if tx.expired(e) {
+		tx.Delete(key)
+		return false, nil
+	}
+	if err := json.Unmarshal(e.Value, value); err != nil {
+		return false, fmt.Errorf("failed to read state %q: %s", key, err)
+	}
+	return true, nil
+}
+
+// Set sets the value of key. The value is stored as JSON.
+func (tx *Tx) Set(key string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to set state %q: %s", key, err)
+	}
+	tx.entries[key] = entry{Value: b, Updated: tx.now}
+	tx.changed = true
+	tx.prune()
+	return nil
+}
+
+// Delete removes key.
+func (tx *Tx) Delete(key string) {
+	if _, ok := tx.entries[key]; ok {
+		delete(tx.entries, key)
+		tx.changed = true
+	}
+}
+
+func (tx *Tx) expired(e entry) bool {
+	return tx.store.ttl > 0 && tx.now.Sub(e.Updated) > tx.store.ttl
+}
+
+// prune removes the expired entries, so that they aren't saved.
+func (tx *Tx) prune() {
+	for key, e := range tx.entries {
+		if tx.expired(e) {
+			delete(tx.entries, key)
+		}
+	}
+}
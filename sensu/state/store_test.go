@@ -0,0 +1,246 @@
+package state
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func openTestStore(t *testing.T, ttl time.Duration) (*Store, *clock) {
+	t.Helper()
+	store, err := Open("test/plugin", Options{Dir: t.TempDir(), TTL: ttl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &clock{t: time.Unix(1700000000, 0)}
+	store.now = c.now
+	return store, c
+}
+
+func TestStoreGetSet(t *testing.T) {
+	store, _ := openTestStore(t, 0)
+	assert.Equal(t, "test_plugin.json", store.Path()[len(store.Path())-len("test_plugin.json"):])
+
+	var value string
+	found, err := store.Get("missing", &value)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	_, err = os.Stat(store.Path())
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, store.Set("key", "value"))
+	found, err = store.Get("key", &value)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "value", value)
+
+	assert.NoError(t, store.Delete("key"))
+	found, err = store.Get("key", &value)
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestStoreTTL(t *testing.T) {
+	store, c := openTestStore(t, time.Minute)
+	assert.NoError(t, store.Set("old", 1))
+	c.t = c.t.Add(30 * time.Second)
+	assert.NoError(t, store.Set("new", 2))
+
+	var value int
+	found, _ := store.Get("old", &value)
+	assert.True(t, found)
+
+	c.t = c.t.Add(time.Minute)
+	found, _ = store.Get("old", &value)
+	assert.False(t, found)
+	found, _ = store.Get("new", &value)
+	assert.True(t, found)
+}
+
+func TestStoreUpdateError(t *testing.T) {
+	store, _ := openTestStore(t, 0)
+	assert.NoError(t, store.Set("key", 1))
+	err := store.Update(func(tx *Tx) error {
+		_ = tx.Set("key", 2)
+		return os.ErrInvalid
+	})
+	assert.Equal(t, os.ErrInvalid, err)
+
+	var value int
+	_, _ = store.Get("key", &value)
+	assert.Equal(t, 1, value)
+}
+
+func TestStoreCorruptFile(t *testing.T) {
+	store, _ := openTestStore(t, 0)
+	assert.NoError(t, ioutil.WriteFile(store.Path(), []byte("{not json"), 0600))
+	logged := new(bytes.Buffer)
+	log.SetOutput(logged)
+	defer log.SetOutput(os.Stderr)
+	var value int
+	found, err := store.Get("key", &value)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Contains(t, logged.String(), "discarding corrupt state "+store.Path())
+	assert.NoError(t, store.Set("key", 1))
+}
+
+func TestStoreLock(t *testing.T) {
+	store, _ := openTestStore(t, 0)
+	store.lockTimeout = 50 * time.Millisecond
+
+	unlock, err := store.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ErrLocked, store.Set("key", 1))
+	unlock()
+	assert.NoError(t, store.Set("key", 1))
+
+	// a stale lock is removed
+	lock := store.Path() + ".lock"
+	assert.NoError(t, ioutil.WriteFile(lock, nil, 0600))
+	old := time.Now().Add(-2 * staleLockAge)
+	assert.NoError(t, os.Chtimes(lock, old, old))
+	assert.NoError(t, store.Set("key", 2))
+}
+
+func TestStoreLockToken(t *testing.T) {
+	store, _ := openTestStore(t, 0)
+	lock := store.Path() + ".lock"
+
+	// the lock file is only removed by its holder
+	unlock, err := store.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, ioutil.WriteFile(lock, []byte("another-holder"), 0600))
+	unlock()
+	_, err = os.Stat(lock)
+	assert.NoError(t, err)
+}
+
+func TestStoreLockRefresh(t *testing.T) {
+	defer func(interval time.Duration) { lockRefreshInterval = interval }(lockRefreshInterval)
+	lockRefreshInterval = 10 * time.Millisecond
+	store, _ := openTestStore(t, 0)
+	store.lockTimeout = 100 * time.Millisecond
+	lock := store.Path() + ".lock"
+
+	// a lock held for longer than staleLockAge isn't stale
+	unlock, err := store.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	assert.NoError(t, os.Chtimes(lock, old, old))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, ErrLocked, store.Set("key", 1))
+	unlock()
+	assert.NoError(t, store.Set("key", 1))
+}
+
+func TestStoreStaleLockConcurrentUpdates(t *testing.T) {
+	store, _ := openTestStore(t, 0)
+	lock := store.Path() + ".lock"
+	assert.NoError(t, ioutil.WriteFile(lock, []byte("killed-holder"), 0600))
+	old := time.Now().Add(-2 * staleLockAge)
+	assert.NoError(t, os.Chtimes(lock, old, old))
+
+	// the stale lock is broken once, and the updates are still exclusive
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, store.Update(func(tx *Tx) error {
+				var n int
+				if _, err := tx.Get("n", &n); err != nil {
+					return err
+				}
+				time.Sleep(time.Millisecond)
+				return tx.Set("n", n+1)
+			}))
+		}()
+	}
+	wg.Wait()
+	var n int
+	_, _ = store.Get("n", &n)
+	assert.Equal(t, 10, n)
+	matches, _ := filepath.Glob(lock + "*")
+	assert.Empty(t, matches)
+}
+
+func TestStoreConcurrentUpdates(t *testing.T) {
+	store, _ := openTestStore(t, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, store.Update(func(tx *Tx) error {
+				var n int
+				if _, err := tx.Get("n", &n); err != nil {
+					return err
+				}
+				return tx.Set("n", n+1)
+			}))
+		}()
+	}
+	wg.Wait()
+	var n int
+	_, _ = store.Get("n", &n)
+	assert.Equal(t, 10, n)
+}
+
+func TestRates(t *testing.T) {
+	store, c := openTestStore(t, 0)
+	eth0 := []*corev2.MetricTag{{Name: "interface", Value: "eth0"}}
+	eth1 := []*corev2.MetricTag{{Name: "interface", Value: "eth1"}}
+
+	rates, err := Rates(store, metric.Points{
+		{Name: "rx_bytes", Value: 1000, Tags: eth0},
+		{Name: "rx_bytes", Value: 5000, Tags: eth1},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, rates)
+
+	c.t = c.t.Add(10 * time.Second)
+	rates, err = Rates(store, metric.Points{
+		{Name: "rx_bytes", Value: 3000, Tags: eth0},
+		{Name: "rx_bytes", Value: 100, Tags: eth1},
+		{Name: "tx_bytes", Value: 100, Tags: eth0},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, rates, 1) {
+		assert.Equal(t, "rx_bytes", rates[0].Name)
+		assert.Equal(t, float64(200), rates[0].Value)
+		assert.Equal(t, eth0, rates[0].Tags)
+		assert.Equal(t, c.t.Unix(), rates[0].Timestamp)
+	}
+
+	// after a reset, the rate is computed from the new value
+	c.t = c.t.Add(time.Second)
+	rates, err = Rates(store, metric.Points{{Name: "rx_bytes", Value: 150, Tags: eth1}})
+	assert.NoError(t, err)
+	if assert.Len(t, rates, 1) {
+		assert.Equal(t, float64(50), rates[0].Value)
+	}
+}
@@ -0,0 +1,222 @@
+package sensu
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// DefaultSubCheckConcurrency is the number of sub-checks run at the same
+// time, if SubCheckOptions.Concurrency is not set.
+const DefaultSubCheckConcurrency = 8
+
+// SubCheck is one of the checks combined by RunSubChecks, such as the check
+// of one endpoint or one disk.
+type SubCheck struct {
+	// Name identifies the sub-check in the output and metrics.
+	Name string
+
+	// Run runs the sub-check. It should stop when ctx is done. An error
+	// results in CheckStateUnknown, unless it was marked with Warning or
+	// Critical.
+	Run func(ctx context.Context) (*CheckResult, error)
+}
+
+// Aggregation combines the statuses of sub-checks into the status of the
+// check.
+type Aggregation func(statuses []int) int
+
+// WorstOf is the Aggregation that results in the worst status of the
+// sub-checks. From best to worst, the statuses are OK, WARNING, UNKNOWN and
+// CRITICAL.
+func WorstOf(statuses []int) int {
+	worst := CheckStateOK
+	for _, status := range statuses {
+		if severity(status) > severity(worst) {
+			worst = status
+		}
+	}
+	return worst
+}
+
+// Quorum returns an Aggregation that results in OK if at least min of the
+// sub-checks are OK, and in the worst status of the sub-checks otherwise.
+func Quorum(min int) Aggregation {
+	return func(statuses []int) int {
+		ok := 0
+		for _, status := range statuses {
+			if status == CheckStateOK {
+				ok++
+			}
+		}
+		if ok >= min {
+			return CheckStateOK
+		}
+		return WorstOf(statuses)
+	}
+}
+
+// severity orders statuses from best to worst.
+func severity(status int) int {
+	switch status {
+	case CheckStateOK:
+		return 0
+	case CheckStateWarning:
+		return 1
+	case CheckStateCritical:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// SubCheckOptions control how RunSubChecks runs and combines sub-checks.
+type SubCheckOptions struct {
+	// Concurrency is the maximum number of sub-checks run at the same time.
+	// The default is DefaultSubCheckConcurrency.
+	Concurrency int
+
+	// Timeout is the time allowed for each sub-check. A sub-check that takes
+	// longer is UNKNOWN. Zero means no timeout.
+	Timeout time.Duration
+
+	// Aggregate combines the statuses of the sub-checks. The default is
+	// WorstOf.
+	Aggregate Aggregation
+
+	// StatusMetrics adds a metric point with the status of each sub-check,
+	// named "subcheck_status" and tagged with the sub-check's name.
+	StatusMetrics bool
+}
+
+// RunSubChecks runs checks concurrently and combines their results. The
+// result has a line of details for each sub-check, in the order of checks,
+// and the metrics and perfdata of all of them.
+func RunSubChecks(ctx context.Context, checks []SubCheck, options SubCheckOptions) *CheckResult {
+	concurrency := options.Concurrency
+	if concurrency < 1 {
+		concurrency = DefaultSubCheckConcurrency
+	}
+	aggregate := options.Aggregate
+	if aggregate == nil {
+		aggregate = WorstOf
+	}
+
+	results := make([]*CheckResult, len(checks))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		i, check := i, check
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			results[i] = &CheckResult{Status: CheckStateUnknown, Summary: ctx.Err().Error()}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// a sub-check that outlives its timeout keeps its slot until it
+			// returns, so that no more than concurrency of them ever run
+			results[i] = runSubCheck(ctx, check, options.Timeout, func() { <-semaphore })
+		}()
+	}
+	wg.Wait()
+
+	result := &CheckResult{}
+	statuses := make([]int, len(results))
+	counts := make(map[int]int)
+	timestamp := time.Now().Unix()
+	for i, r := range results {
+		statuses[i] = r.Status
+		counts[r.Status]++
+		line := fmt.Sprintf("%s: %s", checks[i].Name, StatusName(r.Status))
+		if r.Summary != "" {
+			line += ": " + singleLine(r.Summary)
+		}
+		result.Details = append(result.Details, line)
+		result.Metrics = append(result.Metrics, r.Metrics...)
+		result.Perfdata = append(result.Perfdata, r.Perfdata...)
+		if options.StatusMetrics {
+			result.Metrics = append(result.Metrics, &corev2.MetricPoint{
+				Name:      "subcheck_status",
+				Value:     float64(r.Status),
+				Timestamp: timestamp,
+				Tags:      []*corev2.MetricTag{{Name: "subcheck", Value: checks[i].Name}},
+			})
+		}
+	}
+	result.Status = aggregate(statuses)
+	result.Summary = subCheckSummary(counts, len(results))
+	return result
+}
+
+// runSubCheck runs check, converting an error, a timeout or a panic into an
+// UNKNOWN result. It returns when the check does or times out, but release is
+// only called once check.Run has returned.
+func runSubCheck(ctx context.Context, check SubCheck, timeout time.Duration, release func()) *CheckResult {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan *CheckResult, 1)
+	go func() {
+		defer release()
+		defer func() {
+			if value := recover(); value != nil {
+				done <- &CheckResult{Status: CheckStateUnknown, Summary: fmt.Sprintf("panicked: %v", value)}
+			}
+		}()
+		result, err := check.Run(ctx)
+		if err != nil {
+			status := CheckStateUnknown
+			if s, ok := ErrorStatus(err); ok {
+				status = s
+			}
+			result = &CheckResult{Status: status, Summary: err.Error()}
+		}
+		if result == nil {
+			result = &CheckResult{Status: CheckStateUnknown, Summary: "no result"}
+		}
+		done <- result
+	}()
+
+	select {
+	case result := <-done:
+		return result
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded && timeout > 0 {
+			return &CheckResult{Status: CheckStateUnknown, Summary: fmt.Sprintf("timed out after %s", timeout)}
+		}
+		return &CheckResult{Status: CheckStateUnknown, Summary: ctx.Err().Error()}
+	}
+}
+
+// subCheckSummary summarizes the statuses of the sub-checks, for example
+// "3 of 4 OK, 1 CRITICAL".
+func subCheckSummary(counts map[int]int, total int) string {
+	statuses := make([]int, 0, len(counts))
+	for status := range counts {
+		if status != CheckStateOK {
+			statuses = append(statuses, status)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if severity(statuses[i]) != severity(statuses[j]) {
+			return severity(statuses[i]) > severity(statuses[j])
+		}
+		return statuses[i] < statuses[j]
+	})
+	parts := []string{fmt.Sprintf("%d of %d OK", counts[CheckStateOK], total)}
+	for _, status := range statuses {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[status], StatusName(status)))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,123 @@
+package sensu
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func staticSubCheck(name string, status int, summary string) SubCheck {
+	return SubCheck{Name: name, Run: func(context.Context) (*CheckResult, error) {
+		return &CheckResult{Status: status, Summary: summary}, nil
+	}}
+}
+
+func TestAggregations(t *testing.T) {
+	assert.Equal(t, CheckStateOK, WorstOf(nil))
+	assert.Equal(t, CheckStateWarning, WorstOf([]int{0, 1, 0}))
+	assert.Equal(t, CheckStateUnknown, WorstOf([]int{1, 3, 0}))
+	assert.Equal(t, CheckStateCritical, WorstOf([]int{3, 2, 1}))
+
+	quorum := Quorum(2)
+	assert.Equal(t, CheckStateOK, quorum([]int{0, 0, 2}))
+	assert.Equal(t, CheckStateCritical, quorum([]int{0, 2, 1}))
+}
+
+func TestRunSubChecks(t *testing.T) {
+	checks := []SubCheck{
+		staticSubCheck("web1", CheckStateOK, "200 OK"),
+		{Name: "web2", Run: func(context.Context) (*CheckResult, error) {
+			return nil, Critical(errors.New("connection refused"))
+		}},
+		{Name: "web3", Run: func(ctx context.Context) (*CheckResult, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}},
+		{Name: "web4", Run: func(context.Context) (*CheckResult, error) {
+			return &CheckResult{Status: CheckStateOK, Metrics: []*corev2.MetricPoint{{Name: "latency", Value: 0.2}}}, nil
+		}},
+		{Name: "web5", Run: func(context.Context) (*CheckResult, error) {
+			panic("broken")
+		}},
+	}
+
+	result := RunSubChecks(context.Background(), checks, SubCheckOptions{
+		Concurrency:   2,
+		Timeout:       20 * time.Millisecond,
+		StatusMetrics: true,
+	})
+
+	assert.Equal(t, CheckStateCritical, result.Status)
+	assert.Equal(t, "2 of 5 OK, 1 CRITICAL, 2 UNKNOWN", result.Summary)
+	assert.Equal(t, []string{
+		"web1: OK: 200 OK",
+		"web2: CRITICAL: connection refused",
+		"web3: UNKNOWN: timed out after 20ms",
+		"web4: OK",
+		"web5: UNKNOWN: panicked: broken",
+	}, result.Details)
+
+	if assert.Len(t, result.Metrics, 6) {
+		assert.Equal(t, "subcheck_status", result.Metrics[0].Name)
+		assert.Equal(t, "web1", result.Metrics[0].Tags[0].Value)
+		assert.Equal(t, "latency", result.Metrics[3].Name)
+		assert.Equal(t, float64(CheckStateUnknown), result.Metrics[5].Value)
+	}
+
+	quorum := RunSubChecks(context.Background(), checks, SubCheckOptions{
+		Timeout:   20 * time.Millisecond,
+		Aggregate: Quorum(2),
+	})
+	assert.Equal(t, CheckStateOK, quorum.Status)
+	assert.Len(t, quorum.Metrics, 1)
+}
+
+func TestRunSubChecksConcurrency(t *testing.T) {
+	var running, max int32
+	check := SubCheck{Name: "slow", Run: func(context.Context) (*CheckResult, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return &CheckResult{Status: CheckStateOK}, nil
+	}}
+
+	result := RunSubChecks(context.Background(), []SubCheck{check, check, check, check, check, check}, SubCheckOptions{Concurrency: 3})
+	assert.Equal(t, CheckStateOK, result.Status)
+	assert.Equal(t, "6 of 6 OK", result.Summary)
+	assert.LessOrEqual(t, max, int32(3))
+}
+
+func TestRunSubChecksConcurrencyTimeouts(t *testing.T) {
+	var running, max int32
+	// the sub-check ignores its context, and keeps running after it times out
+	check := SubCheck{Name: "stuck", Run: func(context.Context) (*CheckResult, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(30 * time.Millisecond)
+		return &CheckResult{Status: CheckStateOK}, nil
+	}}
+
+	result := RunSubChecks(context.Background(), []SubCheck{check, check, check, check, check, check},
+		SubCheckOptions{Concurrency: 2, Timeout: 5 * time.Millisecond})
+	assert.Equal(t, CheckStateUnknown, result.Status)
+	assert.Equal(t, "0 of 6 OK, 6 UNKNOWN", result.Summary)
+	assert.Contains(t, result.Details[0], "timed out after 5ms")
+	assert.LessOrEqual(t, atomic.LoadInt32(&max), int32(2))
+}
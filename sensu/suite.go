@@ -0,0 +1,114 @@
+package sensu
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/sensu/sensu-plugin-sdk/version"
+	"github.com/spf13/cobra"
+)
+
+// Plugin is implemented by Check, Handler and Mutator. It allows plugins to be
+// composed into a Suite.
+type Plugin interface {
+	Execute()
+	getFramework() *pluginFramework
+}
+
+// Suite is a framework for shipping several plugins in a single binary. Each
+// plugin is registered as a subcommand of the suite, named after the Name in
+// its PluginConfig, and keeps its own options and Keyspace.
+type Suite struct {
+	config           *PluginConfig
+	options          []ConfigOption
+	plugins          map[*cobra.Command]*pluginFramework
+	cmd              *cobra.Command
+	exitFunction     func(int)
+	errorLogFunction func(format string, a ...interface{})
+}
+
+// NewSuite creates a new suite. The options are shared by all of the plugins
+// in the suite, and are available as persistent flags on every subcommand.
+// Annotation overrides for shared options are resolved using the Keyspace of
+// the plugin being executed.
+func NewSuite(config *PluginConfig, options []ConfigOption, plugins ...Plugin) *Suite {
+	suite := &Suite{
+		config:  config,
+		options: options,
+		plugins: make(map[*cobra.Command]*pluginFramework),
+	}
+	if err := suite.init(plugins); err != nil {
+		log.Printf("failed to initialize plugin suite: %s", err)
+	}
+	return suite
+}
+
+func (s *Suite) init(plugins []Plugin) error {
+	s.cmd = &cobra.Command{
+		Use:           s.config.Name,
+		Short:         s.config.Short,
+		SilenceErrors: true,
+	}
+	s.exitFunction = os.Exit
+	s.errorLogFunction = func(format string, a ...interface{}) {
+		_, _ = fmt.Fprintf(os.Stderr, format, a...)
+	}
+
+	s.cmd.AddCommand(&cobra.Command{
+		Use:           "version",
+		Short:         "Print the version number of this plugin",
+		SilenceErrors: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Println(version.Version())
+		},
+	})
+	setupCompletion(s.cmd)
+
+	// Options are set up on a scratch command so that their flags can be
+	// promoted to persistent flags of the suite.
+	scratch := &cobra.Command{}
+	for _, opt := range s.options {
+		if err := opt.SetupFlag(scratch); err != nil {
+			return err
+		}
+	}
+	s.cmd.PersistentFlags().AddFlagSet(scratch.Flags())
+
+	for _, plugin := range plugins {
+		if err := s.add(plugin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Suite) add(plugin Plugin) error {
+	framework := plugin.getFramework()
+	if framework.cmd == nil {
+		return errors.New("plugin must be initialized")
+	}
+	framework.sharedOptions = s.options
+	s.plugins[framework.cmd] = framework
+	s.cmd.AddCommand(framework.cmd)
+	return nil
+}
+
+// Execute is the suite's entry point. It executes the plugin selected by the
+// first command line argument, and exits with that plugin's exit status.
+func (s *Suite) Execute() {
+	cmd, err := s.cmd.ExecuteC()
+	if err != nil {
+		s.errorLogFunction("Error executing %s: %v\n", s.config.Name, err)
+	}
+	if framework, ok := s.plugins[cmd]; ok {
+		s.exitFunction(framework.exitStatus)
+		return
+	}
+	if err != nil {
+		s.exitFunction(1)
+		return
+	}
+	s.exitFunction(0)
+}
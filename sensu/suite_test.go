@@ -0,0 +1,91 @@
+package sensu
+
+import (
+	"fmt"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func suiteExecuteUtil(t *testing.T, suite *Suite, args []string) (int, string) {
+	t.Helper()
+
+	var exitStatus = -99
+	var errorStr string
+	suite.cmd.SetArgs(args)
+	suite.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	suite.errorLogFunction = func(format string, a ...interface{}) {
+		errorStr = fmt.Sprintf(format, a...)
+	}
+	suite.Execute()
+
+	return exitStatus, errorStr
+}
+
+func TestSuite_Execute(t *testing.T) {
+	var shared string
+	var diskValue, inodeValue uint64
+	var diskCalled, inodeCalled bool
+
+	sharedOpt := stringOpt
+	sharedOpt.Value = &shared
+	diskOpt := uint64Opt
+	diskOpt.Value = &diskValue
+	inodeOpt := uint64Opt
+	inodeOpt.Value = &inodeValue
+
+	noValidation := func(*corev2.Event) (int, error) { return 0, nil }
+	disk := NewCheck(&PluginConfig{Name: "disk", Short: "disk check"}, []ConfigOption{&diskOpt},
+		noValidation, func(*corev2.Event) (int, error) {
+			diskCalled = true
+			return CheckStateWarning, nil
+		}, false)
+	inode := NewCheck(&PluginConfig{Name: "inode", Short: "inode check"}, []ConfigOption{&inodeOpt},
+		noValidation, func(*corev2.Event) (int, error) {
+			inodeCalled = true
+			return CheckStateOK, nil
+		}, false)
+
+	suite := NewSuite(&PluginConfig{Name: "storage", Short: "storage checks"}, []ConfigOption{&sharedOpt}, disk, inode)
+
+	status, errorStr := suiteExecuteUtil(t, suite, []string{"disk", "--string", "shared-value", "--uint64", "42"})
+	assert.Equal(t, CheckStateWarning, status)
+	assert.Empty(t, errorStr)
+	assert.True(t, diskCalled)
+	assert.False(t, inodeCalled)
+	assert.Equal(t, "shared-value", shared)
+	assert.Equal(t, uint64(42), diskValue)
+
+	status, _ = suiteExecuteUtil(t, suite, []string{"inode"})
+	assert.Equal(t, CheckStateOK, status)
+	assert.True(t, inodeCalled)
+}
+
+func TestSuite_ExecuteUnknownCommand(t *testing.T) {
+	noOp := func(*corev2.Event) (int, error) { return 0, nil }
+	check := NewCheck(&PluginConfig{Name: "disk"}, nil, noOp, noOp, false)
+	suite := NewSuite(&PluginConfig{Name: "storage"}, nil, check)
+
+	status, errorStr := suiteExecuteUtil(t, suite, []string{"mount"})
+	assert.Equal(t, 1, status)
+	assert.Contains(t, errorStr, "unknown command")
+}
+
+func TestSuite_SharedOptionKeyspace(t *testing.T) {
+	var shared string
+	sharedOpt := stringOpt
+	sharedOpt.Value = &shared
+
+	noOp := func(*corev2.Event) error { return nil }
+	handler := NewHandler(&defaultHandlerConfig, nil, noOp, noOp)
+	handler.framework.eventReader = getFileReader("test/event-check-override.json")
+	suite := NewSuite(&PluginConfig{Name: "suite"}, []ConfigOption{&sharedOpt}, handler)
+
+	status, errorStr := suiteExecuteUtil(t, suite, []string{defaultHandlerConfig.Name})
+	assert.Equal(t, 0, status)
+	assert.Empty(t, errorStr)
+	assert.Equal(t, "value-check1", shared)
+}
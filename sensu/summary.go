@@ -0,0 +1,214 @@
+package sensu
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	corev2 "github.com/sensu/core/v2"
+)
+
+// summaryEllipsis marks a summary that was trimmed.
+const summaryEllipsis = "..."
+
+// DescriptionAnnotations are the check annotations that SummaryBuilder reads
+// an event's description from, in order. They correspond to the notification
+// and description check attributes of the Ruby plugin SDK.
+var DescriptionAnnotations = []string{"notification", "description"}
+
+// SummaryBuilder builds one-line event summaries for notifications, such as
+//
+//	CRITICAL webserver01/check-nginx: Nginx is down: connection refused (3 occurrences, for 2m0s)
+//
+// Each part of the summary can be left out.
+type SummaryBuilder struct {
+	// Entity and Check include the entity and check names.
+	Entity bool
+	Check  bool
+
+	// Status includes the name of the check's status.
+	Status bool
+
+	// Description includes the check's description annotation, from
+	// DescriptionAnnotations.
+	Description bool
+
+	// Output includes the first non-empty line of the check's output.
+	Output bool
+
+	// Occurrences includes the number of occurrences of the check's status.
+	Occurrences bool
+
+	// Duration includes how long the incident has lasted, see
+	// IncidentDuration.
+	Duration bool
+
+	// MaxLength, if positive, is the maximum length of the summary. A longer
+	// summary is trimmed, and ends with "...".
+	MaxLength int
+
+	// TrimBytes measures MaxLength in bytes, rather than runes. The summary
+	// is never trimmed in the middle of a rune.
+	TrimBytes bool
+}
+
+// NewSummaryBuilder returns a SummaryBuilder that includes every part of the
+// summary, and doesn't trim it.
+func NewSummaryBuilder() *SummaryBuilder {
+	return &SummaryBuilder{
+		Entity:      true,
+		Check:       true,
+		Status:      true,
+		Description: true,
+		Output:      true,
+		Occurrences: true,
+		Duration:    true,
+	}
+}
+
+// Build returns the event's summary.
+func (b *SummaryBuilder) Build(event *corev2.Event) string {
+	var check *corev2.Check
+	if event != nil {
+		check = event.Check
+	}
+
+	var head, body, tail []string
+	if b.Status && check != nil {
+		head = append(head, StatusName(int(check.Status)))
+	}
+	var names []string
+	if b.Entity {
+		name := nilStr
+		if event != nil && event.Entity != nil && len(event.Entity.Name) > 0 {
+			name = event.Entity.Name
+		}
+		names = append(names, name)
+	}
+	if b.Check {
+		name := nilStr
+		if check != nil && len(check.Name) > 0 {
+			name = check.Name
+		}
+		names = append(names, name)
+	}
+	if len(names) > 0 {
+		head = append(head, strings.Join(names, "/"))
+	}
+	if b.Description && check != nil {
+		for _, annotation := range DescriptionAnnotations {
+			if description := strings.TrimSpace(check.Annotations[annotation]); description != "" {
+				body = append(body, description)
+				break
+			}
+		}
+	}
+	if b.Output && check != nil {
+		if line := firstLine(check.Output); line != "" {
+			body = append(body, line)
+		}
+	}
+	if b.Occurrences && check != nil && check.Occurrences > 0 {
+		if check.Occurrences == 1 {
+			tail = append(tail, "1 occurrence")
+		} else {
+			tail = append(tail, fmt.Sprintf("%d occurrences", check.Occurrences))
+		}
+	}
+	if b.Duration {
+		if d := IncidentDuration(event); d > 0 {
+			tail = append(tail, "for "+d.String())
+		}
+	}
+
+	summary := strings.Join(head, " ")
+	if len(body) > 0 {
+		if summary != "" {
+			summary += ": "
+		}
+		summary += strings.Join(body, ": ")
+	}
+	if len(tail) > 0 {
+		if summary != "" {
+			summary += " "
+		}
+		summary += "(" + strings.Join(tail, ", ") + ")"
+	}
+	return b.trim(summary)
+}
+
+// trim shortens the summary to MaxLength, marking it with summaryEllipsis.
+func (b *SummaryBuilder) trim(summary string) string {
+	if b.MaxLength <= 0 || b.length(summary) <= b.MaxLength {
+		return summary
+	}
+	ellipsis := summaryEllipsis
+	if b.MaxLength <= len(ellipsis) {
+		ellipsis = ""
+	}
+	return truncate(summary, b.MaxLength-len(ellipsis), b.TrimBytes) + ellipsis
+}
+
+func (b *SummaryBuilder) length(s string) int {
+	if b.TrimBytes {
+		return len(s)
+	}
+	return utf8.RuneCountInString(s)
+}
+
+// truncate returns the longest prefix of s that is at most max runes, or
+// bytes, long, without splitting a rune.
+func truncate(s string, max int, bytes bool) string {
+	if max <= 0 {
+		return ""
+	}
+	if bytes {
+		if len(s) <= max {
+			return s
+		}
+		cut := max
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		return s[:cut]
+	}
+	for i := range s {
+		if max == 0 {
+			return s[:i]
+		}
+		max--
+	}
+	return s
+}
+
+// firstLine returns the first line of s that isn't blank, without surrounding
+// whitespace.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// TemplateFuncMap returns the template functions of the sensu package, for
+// templates.EvalTemplateWithFuncs:
+//
+//	Summary builds the event's summary with a SummaryBuilder that includes
+//	every part of it. An optional maximum length, in runes, trims the
+//	summary: {{ Summary . 150 }}
+func TemplateFuncMap() template.FuncMap {
+	return template.FuncMap{
+		"Summary": templateSummary,
+	}
+}
+
+func templateSummary(event *corev2.Event, maxLength ...int) string {
+	builder := NewSummaryBuilder()
+	if len(maxLength) > 0 {
+		builder.MaxLength = maxLength[0]
+	}
+	return builder.Build(event)
+}
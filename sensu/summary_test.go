@@ -0,0 +1,92 @@
+package sensu
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/templates"
+	"github.com/stretchr/testify/assert"
+)
+
+func summaryEvent() *corev2.Event {
+	event := classifiedEvent(0, 2, 2, 2)
+	event.Check.Output = "\n  connection refused  \nretrying\n"
+	event.Check.Occurrences = 3
+	event.Check.Annotations = map[string]string{"description": "Nginx is down"}
+	return event
+}
+
+func TestSummaryBuilder(t *testing.T) {
+	builder := NewSummaryBuilder()
+	assert.Equal(t, "CRITICAL entity/check: Nginx is down: connection refused (3 occurrences, for 3m0s)",
+		builder.Build(summaryEvent()))
+
+	event := summaryEvent()
+	event.Check.Annotations["notification"] = "Web server unavailable"
+	builder.Duration = false
+	assert.Equal(t, "CRITICAL entity/check: Web server unavailable: connection refused (3 occurrences)",
+		builder.Build(event))
+
+	builder = &SummaryBuilder{Check: true, Output: true}
+	assert.Equal(t, "check: connection refused", builder.Build(summaryEvent()))
+
+	builder = &SummaryBuilder{Output: true}
+	assert.Equal(t, "connection refused", builder.Build(summaryEvent()))
+
+	assert.Equal(t, "nil/nil", NewSummaryBuilder().Build(nil))
+
+	event = classifiedEvent(2, 0)
+	event.Check.Occurrences = 1
+	assert.Equal(t, "OK entity/check (1 occurrence)", NewSummaryBuilder().Build(event))
+}
+
+func TestSummaryBuilderTrim(t *testing.T) {
+	event := summaryEvent()
+	event.Check.Output = "état critique"
+	builder := &SummaryBuilder{Output: true, MaxLength: 8}
+	assert.Equal(t, "état ...", builder.Build(event))
+
+	// "é" is two bytes, and isn't split
+	builder.TrimBytes = true
+	builder.MaxLength = 5
+	assert.Equal(t, "é...", builder.Build(event))
+
+	builder.MaxLength = 3
+	assert.Equal(t, "ét", builder.Build(event))
+
+	builder.MaxLength = 1
+	assert.Equal(t, "", builder.Build(event))
+
+	builder.MaxLength = 100
+	assert.Equal(t, "état critique", builder.Build(event))
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "ét", truncate("état", 2, false))
+	assert.Equal(t, "é", truncate("état", 2, true))
+	assert.Equal(t, "ét", truncate("état", 3, true))
+	assert.Equal(t, "état", truncate("état", 10, false))
+	assert.Equal(t, "", truncate("état", 0, false))
+}
+
+func TestEventSummaryWithTrimMultibyte(t *testing.T) {
+	event := summaryEvent()
+	event.Check.Output = "ééé"
+	assert.Equal(t, "entity/check : éé", EventSummaryWithTrim(event, 2))
+	assert.Equal(t, "entity/check : ééé", EventSummaryWithTrim(event, 4))
+}
+
+func TestSummaryTemplateFunc(t *testing.T) {
+	event := summaryEvent()
+	result, err := templates.EvalTemplateWithFuncs("summary", "{{ Summary . }}", event, TemplateFuncMap())
+	assert.NoError(t, err)
+	assert.Equal(t, "CRITICAL entity/check: Nginx is down: connection refused (3 occurrences, for 3m0s)", result)
+
+	result, err = templates.EvalTemplateWithFuncs("summary", "{{ Summary . 30 }}", event, TemplateFuncMap())
+	assert.NoError(t, err)
+	assert.Equal(t, "CRITICAL entity/check: Ngin...", result)
+
+	// the template functions aren't available without the func map
+	_, err = templates.EvalTemplate("summary", "{{ Summary . }}", event)
+	assert.Error(t, err)
+}
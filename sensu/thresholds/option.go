@@ -0,0 +1,114 @@
+package thresholds
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/spf13/cobra"
+)
+
+// Option is a sensu.ConfigOption for a Range. Like sensu.PluginConfigOption,
+// it can be set with a command line argument, an environment variable or a
+// Sensu event annotation.
+type Option struct {
+	// Value is the range to read the configured flag or environment variable
+	// into. It's expected that Value is non-nil.
+	Value *Range
+
+	// Path is the path to the Sensu annotation to consult when parsing config.
+	Path string
+
+	// Env is the environment variable to consult when parsing config.
+	Env string
+
+	// Argument is the command line argument to consult when parsing config.
+	Argument string
+
+	// Shorthand is the shorthand command line argument to consult when parsing config.
+	Shorthand string
+
+	// Default is the default value of the config option, in Nagios threshold
+	// format.
+	Default string
+
+	// Usage adds help context to the command-line flag.
+	Usage string
+}
+
+var (
+	_ sensu.ConfigOption = &Option{}
+	_ sensu.Snapshotter  = &Option{}
+)
+
+// SetupFlag adds the option's flag to cmd. The environment variable, if it is
+// set, takes precedence over the default value.
+func (o *Option) SetupFlag(cmd *cobra.Command) error {
+	if len(o.Argument) == 0 {
+		return nil
+	}
+	if o.Value == nil {
+		return fmt.Errorf("setup flag: %s: couldn't write into nil value", o.Argument)
+	}
+	value := o.Default
+	if env := os.Getenv(o.Env); o.Env != "" && env != "" {
+		value = env
+	}
+	if err := o.Value.Set(value); err != nil {
+		return fmt.Errorf("setup flag: %s: %s", o.Argument, err)
+	}
+	cmd.Flags().VarP(o.Value, o.Argument, o.Shorthand, o.Usage)
+	return nil
+}
+
+// SetValue parses valueStr into the option's range.
+func (o *Option) SetValue(valueStr string) error {
+	if o.Value == nil {
+		return errors.New("thresholds.Option.Value not set!")
+	}
+	if err := o.Value.Set(valueStr); err != nil {
+		return fmt.Errorf("%s: %s", o.Argument, err)
+	}
+	return nil
+}
+
+// SetAnnotationValue sets the option value based on a prefix indicated by
+// keyspace, and an event object. The check annotation will be resolved first,
+// followed by the entity annotation.
+func (o *Option) SetAnnotationValue(keySpace string, event *corev2.Event) (sensu.SetAnnotationResult, error) {
+	key := path.Join(keySpace, o.Path)
+	keys := []string{strings.ToLower(key), key}
+	var result sensu.SetAnnotationResult
+	for _, key := range keys {
+		var value string
+		if event.Check != nil {
+			value = event.Check.Annotations[key]
+			result.CheckAnnotation = len(value) > 0
+		}
+		if value == "" && event.Entity != nil {
+			value = event.Entity.Annotations[key]
+			result.EntityAnnotation = len(value) > 0
+		}
+		if len(value) > 0 {
+			result.AnnotationKey = key
+			result.AnnotationValue = value
+			return result, o.SetValue(value)
+		}
+	}
+	return result, nil
+}
+
+// Snapshot saves the option's range, returning a function that restores it.
+func (o *Option) Snapshot() func() {
+	if o.Value == nil {
+		return func() {}
+	}
+	value := *o.Value
+	return func() {
+		*o.Value = value
+	}
+}
@@ -0,0 +1,54 @@
+package thresholds
+
+import (
+	"os"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionSetupFlag(t *testing.T) {
+	var threshold Threshold
+	warning := &Option{Value: &threshold.Warning, Argument: "warning", Default: "80"}
+	critical := &Option{Value: &threshold.Critical, Argument: "critical", Env: "TEST_CRITICAL", Default: "90"}
+	if err := os.Setenv("TEST_CRITICAL", "@95:100"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("TEST_CRITICAL")
+
+	cmd := &cobra.Command{Run: func(*cobra.Command, []string) {}, SilenceErrors: true, SilenceUsage: true}
+	assert.NoError(t, warning.SetupFlag(cmd))
+	assert.NoError(t, critical.SetupFlag(cmd))
+	assert.Equal(t, "80", threshold.Warning.String())
+	assert.Equal(t, "@95:100", threshold.Critical.String())
+
+	cmd.SetArgs([]string{"--warning", "~:50"})
+	assert.NoError(t, cmd.Execute())
+	assert.Equal(t, "~:50", threshold.Warning.String())
+
+	cmd.SetArgs([]string{"--critical", "nope"})
+	assert.Error(t, cmd.Execute())
+}
+
+func TestOptionSetAnnotationValue(t *testing.T) {
+	var r Range
+	opt := &Option{Value: &r, Path: "critical", Argument: "critical", Default: "90"}
+	restore := opt.Snapshot()
+
+	event := corev2.FixtureEvent("entity", "check")
+	event.Check.Annotations = map[string]string{"sensu.io/plugins/test/config/critical": "10:20"}
+	result, err := opt.SetAnnotationValue("sensu.io/plugins/test/config", event)
+	assert.NoError(t, err)
+	assert.True(t, result.CheckAnnotation)
+	assert.Equal(t, "10:20", result.AnnotationValue)
+	assert.Equal(t, "10:20", r.String())
+
+	restore()
+	assert.False(t, r.IsSet())
+
+	event.Check.Annotations["sensu.io/plugins/test/config/critical"] = "20:10"
+	_, err = opt.SetAnnotationValue("sensu.io/plugins/test/config", event)
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+// Package thresholds implements Nagios threshold ranges, for checks that take
+// --warning and --critical flags.
+//
+// A range is written as start:end, and alerts when a value is outside of it,
+// or inside of it if the range starts with @. Both ends are inclusive.
+//
+//	10       alert if < 0 or > 10
+//	10:      alert if < 10
+//	~:10     alert if > 10
+//	10:20    alert if < 10 or > 20
+//	@10:20   alert if >= 10 and <= 20
+//
+// See https://nagios-plugins.org/doc/guidelines.html#THRESHOLDFORMAT
+package thresholds
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// Range is a Nagios threshold range. The zero value is an unset range, which
+// never alerts. Range implements pflag.Value, so it can be used as a flag.
+type Range struct {
+	// Start and End are the ends of the range, which may be infinite.
+	Start float64
+	End   float64
+
+	// Inside makes the range alert when a value is inside of it, rather than
+	// outside.
+	Inside bool
+
+	set bool
+}
+
+// ParseRange parses a range in Nagios threshold format. An empty string is an
+// unset range.
+func ParseRange(s string) (Range, error) {
+	var r Range
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return r, nil
+	}
+	text := s
+	if strings.HasPrefix(s, "@") {
+		r.Inside = true
+		s = s[1:]
+		if s == "" {
+			return Range{}, fmt.Errorf("invalid range %q: missing end", text)
+		}
+	}
+	start, end := "0", s
+	if i := strings.Index(s, ":"); i >= 0 {
+		start, end = s[:i], s[i+1:]
+	}
+
+	var err error
+	switch start {
+	case "~":
+		r.Start = math.Inf(-1)
+	case "":
+		return Range{}, fmt.Errorf("invalid range %q: missing start", text)
+	default:
+		if r.Start, err = parseFloat(start); err != nil {
+			return Range{}, fmt.Errorf("invalid range %q: %s", text, err)
+		}
+	}
+	if end == "" {
+		r.End = math.Inf(1)
+	} else if r.End, err = parseFloat(end); err != nil {
+		return Range{}, fmt.Errorf("invalid range %q: %s", text, err)
+	}
+	if r.Start > r.End {
+		return Range{}, fmt.Errorf("invalid range %q: start is greater than end", text)
+	}
+	r.set = true
+	return r, nil
+}
+
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return f, nil
+}
+
+// IsSet tells if the range was set. An unset range never alerts.
+func (r Range) IsSet() bool {
+	return r.set
+}
+
+// Alert tells if value should raise an alert.
+func (r Range) Alert(value float64) bool {
+	if !r.set {
+		return false
+	}
+	inside := value >= r.Start && value <= r.End
+	return inside == r.Inside
+}
+
+// String returns the range in Nagios threshold format.
+func (r Range) String() string {
+	if !r.set {
+		return ""
+	}
+	var b strings.Builder
+	if r.Inside {
+		b.WriteString("@")
+	}
+	switch {
+	case math.IsInf(r.Start, -1):
+		b.WriteString("~:")
+	case r.Start != 0 || math.IsInf(r.End, 1):
+		b.WriteString(formatFloat(r.Start))
+		b.WriteString(":")
+	}
+	if !math.IsInf(r.End, 1) {
+		b.WriteString(formatFloat(r.End))
+	}
+	return b.String()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Set parses s into the range. It is part of pflag.Value.
+func (r *Range) Set(s string) error {
+	parsed, err := ParseRange(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// Type is part of pflag.Value.
+func (r *Range) Type() string {
+	return "range"
+}
+
+// Threshold holds the warning and critical ranges of a check.
+type Threshold struct {
+	Warning  Range
+	Critical Range
+}
+
+// Evaluate returns sensu.CheckStateCritical if value alerts on the critical
+// range, sensu.CheckStateWarning if it alerts on the warning range, and
+// sensu.CheckStateOK otherwise.
+func (t Threshold) Evaluate(value float64) int {
+	switch {
+	case t.Critical.Alert(value):
+		return sensu.CheckStateCritical
+	case t.Warning.Alert(value):
+		return sensu.CheckStateWarning
+	default:
+		return sensu.CheckStateOK
+	}
+}
@@ -0,0 +1,62 @@
+package thresholds
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Range
+		alert    []float64
+		noAlert  []float64
+	}{
+		{"10", Range{Start: 0, End: 10, set: true}, []float64{-1, 10.5}, []float64{0, 5, 10}},
+		{"10:", Range{Start: 10, End: math.Inf(1), set: true}, []float64{9.9, -5}, []float64{10, 1e9}},
+		{"~:10", Range{Start: math.Inf(-1), End: 10, set: true}, []float64{11}, []float64{-1e9, 10}},
+		{"10:20", Range{Start: 10, End: 20, set: true}, []float64{9, 21}, []float64{10, 15, 20}},
+		{"@10:20", Range{Start: 10, End: 20, Inside: true, set: true}, []float64{10, 15, 20}, []float64{9, 21}},
+		{"-5.5:2.5", Range{Start: -5.5, End: 2.5, set: true}, []float64{-6, 3}, []float64{0}},
+		{"", Range{}, nil, []float64{-1e9, 0, 1e9}},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			r, err := ParseRange(test.input)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, test.expected, r)
+			assert.Equal(t, test.input, r.String())
+			for _, v := range test.alert {
+				assert.True(t, r.Alert(v), "%v should alert", v)
+			}
+			for _, v := range test.noAlert {
+				assert.False(t, r.Alert(v), "%v should not alert", v)
+			}
+		})
+	}
+}
+
+func TestParseRangeInvalid(t *testing.T) {
+	for _, input := range []string{"abc", ":10", "20:10", "10:abc", "@", "NaN", "1:Inf"} {
+		_, err := ParseRange(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestThresholdEvaluate(t *testing.T) {
+	var threshold Threshold
+	assert.NoError(t, threshold.Warning.Set("80"))
+	assert.NoError(t, threshold.Critical.Set("90"))
+
+	assert.Equal(t, sensu.CheckStateOK, threshold.Evaluate(50))
+	assert.Equal(t, sensu.CheckStateWarning, threshold.Evaluate(85))
+	assert.Equal(t, sensu.CheckStateCritical, threshold.Evaluate(95))
+	assert.Equal(t, sensu.CheckStateCritical, threshold.Evaluate(-1))
+
+	assert.Equal(t, sensu.CheckStateOK, Threshold{}.Evaluate(1e9))
+}
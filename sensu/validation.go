@@ -1,5 +1,11 @@
 package sensu
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 // ErrValidationFailed should be returned when a configuration validation
 // function fails.
 type ErrValidationFailed string
@@ -7,3 +13,80 @@ type ErrValidationFailed string
 func (e ErrValidationFailed) Error() string {
 	return string(e)
 }
+
+// validationErrors holds all of the problems found when running a plugin with
+// --validate-only.
+type validationErrors []error
+
+func (e validationErrors) Error() string {
+	var b strings.Builder
+	if len(e) == 1 {
+		b.WriteString("validation failed with 1 problem:")
+	} else {
+		fmt.Fprintf(&b, "validation failed with %d problems:", len(e))
+	}
+	for _, err := range e {
+		fmt.Fprintf(&b, "\n  - %s", err)
+	}
+	return b.String()
+}
+
+// validateOnlyFunction reads the event, applies the configuration overrides,
+// and runs the Allow/Restrict checks and the plugin's validation function
+// without executing the plugin. Rather than stopping at the first problem, it
+// reports every problem it finds.
+func (p *pluginFramework) validateOnlyFunction(args []string) error {
+	var problems validationErrors
+
+	eventRead := true
+	if p.readEvent {
+		if err := p.readSensuEvent(); err != nil {
+			problems = append(problems, err)
+			eventRead = false
+		}
+	}
+
+	options := p.allOptions()
+	overrideFailed := make([]bool, len(options))
+	if p.sensuEvent != nil && p.configurationOverrides && p.config.Keyspace != "" {
+		for i, opt := range options {
+			if err := configurationOverride(p.config, opt, p.sensuEvent, p.verbose); err != nil {
+				problems = append(problems, err)
+				overrideFailed[i] = true
+			}
+		}
+	}
+
+	for i, opt := range options {
+		if overrideFailed[i] {
+			// the override already reported the Allow/Restrict violation
+			continue
+		}
+		if v, ok := opt.(allowRestrictValidator); ok {
+			if err := v.validateAllowRestrict(); err != nil {
+				problems = append(problems, err)
+			}
+		}
+	}
+
+	// The validation function can't be expected to cope with a missing event
+	if eventRead {
+		_, err := p.safeCall(func() (int, error) {
+			return p.pluginValidateFunction(args)
+		})
+		var errs validationErrors
+		if errors.As(err, &errs) {
+			problems = append(problems, errs...)
+		} else if err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if len(problems) > 0 {
+		p.exitStatus = p.errorExitStatus
+		return problems
+	}
+	p.exitStatus = 0
+	_, _ = fmt.Fprintln(p.cmd.OutOrStdout(), "validation succeeded")
+	return nil
+}
@@ -0,0 +1,118 @@
+package sensu
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func validateOnlyExecuteUtil(t *testing.T, eventFile string, args []string, options []ConfigOption,
+	validationFunction func(*corev2.Event) error, executeFunction func(*corev2.Event) error) (int, string, string) {
+	t.Helper()
+
+	handler := NewHandler(&defaultHandlerConfig, options, validationFunction, executeFunction)
+	handler.framework.cmd.SetArgs(append([]string{"--validate-only"}, args...))
+	out := new(bytes.Buffer)
+	handler.framework.cmd.SetOut(out)
+
+	var exitStatus = -99
+	var errorStr string
+	handler.framework.eventReader = getFileReader(eventFile)
+	handler.framework.exitFunction = func(i int) {
+		exitStatus = i
+	}
+	handler.framework.errorLogFunction = func(format string, a ...interface{}) {
+		errorStr = fmt.Sprintf(format, a...)
+	}
+	handler.Execute()
+
+	return exitStatus, out.String(), errorStr
+}
+
+func TestValidateOnly(t *testing.T) {
+	var validateCalled, executeCalled bool
+	clearEnvironment()
+	values := handlerValues{}
+	status, out, errorStr := validateOnlyExecuteUtil(t, "test/event-check-override.json", nil, getHandlerOptions(&values),
+		func(event *corev2.Event) error {
+			validateCalled = true
+			assert.NotNil(t, event)
+			return nil
+		}, func(event *corev2.Event) error {
+			executeCalled = true
+			return nil
+		})
+	assert.Equal(t, 0, status)
+	assert.Empty(t, errorStr)
+	assert.Contains(t, out, "validation succeeded")
+	assert.True(t, validateCalled)
+	assert.False(t, executeCalled)
+	assert.Equal(t, "value-check1", values.arg1)
+	assert.Equal(t, uint64(1357), values.arg2)
+}
+
+func TestValidateOnlyReportsAllProblems(t *testing.T) {
+	var executeCalled bool
+	clearEnvironment()
+	values := handlerValues{}
+	restricted := restrictedIntOpt
+	var restrictedValue int
+	restricted.Value = &restrictedValue
+	options := append(getHandlerOptions(&values), &restricted)
+
+	status, out, errorStr := validateOnlyExecuteUtil(t, "test/event-check-override-invalid-value.json",
+		[]string{"--restrictedint", "45"}, options,
+		func(event *corev2.Event) error {
+			return errors.New("missing webhook url")
+		}, func(event *corev2.Event) error {
+			executeCalled = true
+			return nil
+		})
+	assert.Equal(t, 1, status)
+	assert.Empty(t, out)
+	assert.False(t, executeCalled)
+	assert.Contains(t, errorStr, "validation failed with 4 problems")
+	assert.Contains(t, errorStr, "invalid value for uint64: abc")
+	assert.Contains(t, errorStr, "invalid value for bool: si senor")
+	assert.Contains(t, errorStr, "restrictedint: value not allowed to be 45")
+	assert.Contains(t, errorStr, "missing webhook url")
+}
+
+func TestValidateOnlyInvalidEvent(t *testing.T) {
+	var validateCalled bool
+	clearEnvironment()
+	status, _, errorStr := validateOnlyExecuteUtil(t, "test/event-invalid-json.json", nil, getHandlerOptions(&handlerValues{}),
+		func(event *corev2.Event) error {
+			validateCalled = true
+			return nil
+		}, func(event *corev2.Event) error {
+			return nil
+		})
+	assert.Equal(t, 1, status)
+	assert.Contains(t, errorStr, "validation failed with 1 problem:")
+	assert.Contains(t, errorStr, "failed to unmarshal")
+	assert.False(t, validateCalled)
+}
+
+func TestValidateOnlyExpressionFilters(t *testing.T) {
+	var executeCalled bool
+	clearEnvironment()
+	status, out, errorStr := validateOnlyExecuteUtil(t, "test/event-no-override.json",
+		[]string{"--allow-filter", "event.check.status =="}, getHandlerOptions(&handlerValues{}),
+		func(event *corev2.Event) error {
+			return errors.New("missing webhook url")
+		}, func(event *corev2.Event) error {
+			executeCalled = true
+			return nil
+		})
+	assert.Equal(t, 1, status)
+	assert.Empty(t, out)
+	assert.False(t, executeCalled)
+	assert.Contains(t, errorStr, "validation failed with 2 problems")
+	assert.Contains(t, errorStr, "syntax error")
+	assert.Contains(t, errorStr, "missing webhook url")
+}
@@ -12,6 +12,12 @@ import (
 )
 
 func EvalTemplate(templName, templStr string, templSrc interface{}) (string, error) {
+	return EvalTemplateWithFuncs(templName, templStr, templSrc, nil)
+}
+
+// EvalTemplateWithFuncs is like EvalTemplate, but the template can also use
+// funcs, such as the ones returned by sensu.TemplateFuncMap.
+func EvalTemplateWithFuncs(templName, templStr string, templSrc interface{}, funcs template.FuncMap) (string, error) {
 	if templSrc == nil {
 		return "", fmt.Errorf("must pass in template source")
 	}
@@ -24,7 +30,7 @@ func EvalTemplate(templName, templStr string, templSrc interface{}) (string, err
 		"UUIDFromBytes": uuid.FromBytes,
 		"Hostname":      os.Hostname,
 		"toJSON":        toJSON,
-	}).Parse(templStr)
+	}).Funcs(funcs).Parse(templStr)
 	if err != nil {
 		return "", fmt.Errorf("Error building template: %s", err)
 	}